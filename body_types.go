@@ -1,8 +1,97 @@
 package go_k8_helm
 
+// ServiceDetails is the address of a service
+// IP and Port are the address and first port, Hostname is set for a load balancer with a dns name
+// NodeAddresses are the node addresses to use with the node ports
+// ReadyEndpoints is the number of ready endpoints behind the service from the EndpointSlices
 type ServiceDetails struct {
-	ServiceName string `json:"service_name" yaml:"service_name"`
-	ServiceType string `json:"service_type" yaml:"service_type"`
-	IP          string `json:"ip" yaml:"ip"`
-	Port        int32  `json:"port" yaml:"port"`
+	ServiceName    string               `json:"service_name" yaml:"service_name"`
+	ServiceType    string               `json:"service_type" yaml:"service_type"`
+	IP             string               `json:"ip" yaml:"ip"`
+	Port           int32                `json:"port" yaml:"port"`
+	Namespace      string               `json:"namespace" yaml:"namespace"`
+	Hostname       string               `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	ExternalName   string               `json:"external_name,omitempty" yaml:"external_name,omitempty"`
+	ClusterIPs     []string             `json:"cluster_ips,omitempty" yaml:"cluster_ips,omitempty"`
+	Ports          []ServicePortDetails `json:"ports,omitempty" yaml:"ports,omitempty"`
+	NodeAddresses  []string             `json:"node_addresses,omitempty" yaml:"node_addresses,omitempty"`
+	ReadyEndpoints int                  `json:"ready_endpoints" yaml:"ready_endpoints"`
+	Endpoints      int                  `json:"endpoints" yaml:"endpoints"`
+}
+
+// ServicePortDetails is a port of a service
+type ServicePortDetails struct {
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"`
+	Protocol   string `json:"protocol" yaml:"protocol"`
+	Port       int32  `json:"port" yaml:"port"`
+	TargetPort string `json:"target_port" yaml:"target_port"`
+	NodePort   int32  `json:"node_port,omitempty" yaml:"node_port,omitempty"`
+}
+
+// ObjectRef identifies an object in the cluster
+type ObjectRef struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+}
+
+// IngressURL is a url served by an ingress rule
+// ServiceExists is false when the backend service is missing
+// ServiceError is set when the service could not be looked up e.g. forbidden
+type IngressURL struct {
+	URL           string `json:"url" yaml:"url"`
+	Host          string `json:"host" yaml:"host"`
+	Path          string `json:"path" yaml:"path"`
+	Service       string `json:"service" yaml:"service"`
+	ServicePort   string `json:"service_port" yaml:"service_port"`
+	ServiceExists bool   `json:"service_exists" yaml:"service_exists"`
+	ServiceError  string `json:"service_error,omitempty" yaml:"service_error,omitempty"`
+}
+
+// IngressTLS is a tls secret used by an ingress
+// Error is set when the secret could not be looked up e.g. forbidden
+type IngressTLS struct {
+	SecretName string   `json:"secret_name" yaml:"secret_name"`
+	Hosts      []string `json:"hosts" yaml:"hosts"`
+	Exists     bool     `json:"exists" yaml:"exists"`
+	Error      string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// IngressDetails are the urls and load balancer addresses of an ingress
+type IngressDetails struct {
+	Name      string       `json:"name" yaml:"name"`
+	Namespace string       `json:"namespace" yaml:"namespace"`
+	ClassName string       `json:"class_name" yaml:"class_name"`
+	Addresses []string     `json:"addresses" yaml:"addresses"`
+	URLs      []IngressURL `json:"urls" yaml:"urls"`
+	TLS       []IngressTLS `json:"tls" yaml:"tls"`
+}
+
+// NodeCondition is a condition of a node e.g. Ready or MemoryPressure
+type NodeCondition struct {
+	Type    string `json:"type" yaml:"type"`
+	Status  string `json:"status" yaml:"status"`
+	Reason  string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// NodeDetails is the state and resources of a node
+// Capacity and Allocatable are resource name to quantity e.g. cpu: "4", memory: 16Gi
+type NodeDetails struct {
+	Name           string            `json:"name" yaml:"name"`
+	Roles          []string          `json:"roles" yaml:"roles"`
+	Ready          bool              `json:"ready" yaml:"ready"`
+	Unschedulable  bool              `json:"unschedulable" yaml:"unschedulable"`
+	KubeletVersion string            `json:"kubelet_version" yaml:"kubelet_version"`
+	Addresses      map[string]string `json:"addresses" yaml:"addresses"`
+	Conditions     []NodeCondition   `json:"conditions" yaml:"conditions"`
+	Capacity       map[string]string `json:"capacity" yaml:"capacity"`
+	Allocatable    map[string]string `json:"allocatable" yaml:"allocatable"`
+}
+
+// NodeUpdateReport lists the nodes changed by UpdateNodes
+// DryRun is true when the changes were only validated by the server and not persisted
+type NodeUpdateReport struct {
+	Changed []string `json:"changed" yaml:"changed"`
+	DryRun  bool     `json:"dry_run" yaml:"dry_run"`
 }
@@ -0,0 +1,383 @@
+package go_k8_helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// ExportOptions are the options for ExportNamespace
+// Path is the directory to write one file per object, or the tar.gz file when TarGz is true
+// ExcludeResources are resource names to skip e.g. events, defaults to events, endpoints and endpointslices
+// IncludeOwned also exports objects that are created by another object e.g. pods of a deployment
+// SkipSecrets leaves the Secrets out of the export
+// SecretKey encrypts the Secrets with EncryptSecretYaml, load the same key with LoadSecretKeyFile to restore them
+// Without SkipSecrets or SecretKey the Secrets are written readable, the files are only readable by the owner
+type ExportOptions struct {
+	Path             string   `json:"path" yaml:"path"`
+	TarGz            bool     `json:"tar_gz" yaml:"tar_gz"`
+	ExcludeResources []string `json:"exclude_resources" yaml:"exclude_resources"`
+	IncludeOwned     bool     `json:"include_owned" yaml:"include_owned"`
+	SkipSecrets      bool     `json:"skip_secrets" yaml:"skip_secrets"`
+	SecretKey        []byte   `json:"-" yaml:"-"`
+}
+
+// defaultExcludeResources are the resources that are not exported by default
+var defaultExcludeResources = []string{"events", "endpoints", "endpointslices"}
+
+// restoreOrder is the order kinds are restored in, other kinds come after
+var restoreOrder = []string{
+	"ResourceQuota", "LimitRange", "ServiceAccount", "Secret", "ConfigMap",
+	"PersistentVolumeClaim", "Role", "RoleBinding", "Service",
+	"Deployment", "StatefulSet", "DaemonSet", "Job", "CronJob",
+}
+
+// ExportNamespace exports every namespaced object in a namespace as clean yaml
+// The server populated fields are removed so the objects can be applied again
+// ns: namespace
+// opts: the export options
+// return: the exported objects, error
+func (m *K8) ExportNamespace(ns string, opts ExportOptions) ([]ObjectRef, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("export path is required")
+	}
+	exclude := opts.ExcludeResources
+	if exclude == nil {
+		exclude = defaultExcludeResources
+	}
+	if len(opts.SecretKey) > 0 && len(opts.SecretKey) != 32 {
+		return nil, fmt.Errorf("secret key must be 32 bytes")
+	}
+
+	//***************************************
+	//Find all the namespaced resource types
+	//***************************************
+	dc, err := discovery.NewDiscoveryClientForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := dc.ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	dyn, err := dynamic.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	var exported []ObjectRef
+	for _, list := range resources {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") || !containsString(r.Verbs, "list") || containsString(exclude, r.Name) {
+				continue
+			}
+
+			items, err := dyn.Resource(gv.WithResource(r.Name)).Namespace(ns).List(m.ctx, metav1.ListOptions{})
+			if err != nil {
+				log.Printf("Info: Unable to list %s Error(%s)\n", r.Name, err.Error())
+				continue
+			}
+			for i := range items.Items {
+				obj := &items.Items[i]
+				if !opts.IncludeOwned && len(obj.GetOwnerReferences()) > 0 {
+					continue
+				}
+				if isGeneratedObject(obj) {
+					continue
+				}
+				is_secret := gv.Group == "" && obj.GetKind() == "Secret"
+				if is_secret && opts.SkipSecrets {
+					continue
+				}
+				cleanObject(obj)
+				obj.SetNamespace("")
+
+				data, err := yaml.Marshal(obj.Object)
+				if err != nil {
+					return nil, err
+				}
+				if is_secret && len(opts.SecretKey) > 0 {
+					enc, err := EncryptSecretYaml(string(data), opts.SecretKey)
+					if err != nil {
+						return nil, fmt.Errorf("secret %s: %w", obj.GetName(), err)
+					}
+					data = []byte(enc)
+				}
+				name := strings.ToLower(fmt.Sprintf("%s.%s-%s.yaml", r.Name, gv.Group, obj.GetName()))
+				name = strings.ReplaceAll(name, ".-", "-")
+				files[name] = data
+				exported = append(exported, ObjectRef{Kind: obj.GetKind(), Namespace: ns, Name: obj.GetName()})
+			}
+		}
+	}
+
+	//****************
+	//Write the export
+	//****************
+	if opts.TarGz {
+		err = writeTarGz(opts.Path, files)
+	} else {
+		err = writeFiles(opts.Path, files)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Info: Exported %d objects from Namespace(%s) to %s\n", len(exported), ns, opts.Path)
+	return exported, nil
+}
+
+// RestoreNamespace applies an export made by ExportNamespace using ProcessK8File
+// The objects are applied in dependency order e.g. secrets and configmaps before deployments
+// Use SetAutoCreateNamespace if the namespace does not exist
+// path: the export directory or tar.gz file
+// ns: the namespace to restore into, can be different to the exported namespace
+// return: error
+func (m *K8) RestoreNamespace(path string, ns string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	var files map[string][]byte
+	if info.IsDir() {
+		files, err = readFiles(path)
+	} else {
+		files, err = readTarGz(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	//************************
+	//Sort by dependency order
+	//************************
+	type part struct {
+		order int
+		name  string
+		data  string
+	}
+	var parts []part
+	for name, data := range files {
+		obj, _, err := decodeYaml(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		order := len(restoreOrder)
+		for i, kind := range restoreOrder {
+			if kind == obj.GetKind() {
+				order = i
+			}
+		}
+		parts = append(parts, part{order: order, name: name, data: string(data)})
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		if parts[i].order != parts[j].order {
+			return parts[i].order < parts[j].order
+		}
+		return parts[i].name < parts[j].name
+	})
+
+	var docs []string
+	for _, p := range parts {
+		docs = append(docs, p.data)
+	}
+	return m.ProcessK8File([]byte(strings.Join(docs, "---\n")), ns, true)
+}
+
+// cleanObject removes the server populated fields from an object
+// obj: the object to clean
+func cleanObject(obj *unstructured.Unstructured) {
+	unstructured.RemoveNestedField(obj.Object, "status")
+	for _, field := range []string{"managedFields", "uid", "resourceVersion", "creationTimestamp", "generation", "selfLink", "ownerReferences", "deletionTimestamp", "deletionGracePeriodSeconds"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+
+	annotations := obj.GetAnnotations()
+	delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+	delete(annotations, "deployment.kubernetes.io/revision")
+	for k := range annotations {
+		if strings.HasPrefix(k, "pv.kubernetes.io/") {
+			delete(annotations, k)
+		}
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+
+	switch obj.GetKind() {
+	case "Service":
+		// the cluster ip is assigned by the server
+		if ip, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); ip != "None" {
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+		}
+	case "PersistentVolumeClaim":
+		unstructured.RemoveNestedField(obj.Object, "spec", "volumeName")
+	}
+}
+
+// isGeneratedObject returns true for objects the cluster creates in every namespace
+// obj: the object
+func isGeneratedObject(obj *unstructured.Unstructured) bool {
+	switch obj.GetKind() {
+	case "ServiceAccount":
+		return obj.GetName() == "default"
+	case "ConfigMap":
+		return obj.GetName() == "kube-root-ca.crt"
+	case "Secret":
+		secret_type, _, _ := unstructured.NestedString(obj.Object, "type")
+		return secret_type == "kubernetes.io/service-account-token"
+	}
+	return false
+}
+
+// containsString returns true if the value is in the list
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFiles writes the files to a directory only the owner can read
+// dir: the directory
+// files: file name to contents
+// return: error
+func writeFiles(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	for name, data := range files {
+		if err := writePrivateFile(filepath.Join(dir, name), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePrivateFile writes a file only the owner can read
+// An existing file is truncated and its mode is changed before the data is written
+// path: the file
+// data: the contents
+// return: error
+func writePrivateFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readFiles reads the yaml files in a directory
+// dir: the directory
+// return: file name to contents, error
+func readFiles(dir string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, e := range entries {
+		if e.IsDir() || (filepath.Ext(e.Name()) != ".yaml" && filepath.Ext(e.Name()) != ".yml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[e.Name()] = data
+	}
+	return files, nil
+}
+
+// writeTarGz writes the files to a tar.gz file only the owner can read
+// path: the tar.gz file
+// files: file name to contents
+// return: error
+func writeTarGz(path string, files map[string][]byte) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for name, data := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: now})
+		if err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return writePrivateFile(path, buf.Bytes())
+}
+
+// readTarGz reads the yaml files in a tar.gz file
+// path: the tar.gz file
+// return: file name to contents, error
+func readTarGz(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[header.Name] = data
+	}
+	return files, nil
+}
@@ -0,0 +1,92 @@
+package go_k8_helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteFilesPrivate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "export")
+	files := map[string][]byte{"secrets-db.yaml": []byte("kind: Secret\n")}
+	if err := writeFiles(dir, files); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Fatalf("directory mode = %o, want 700", perm)
+	}
+
+	// an existing readable file is made private
+	path := filepath.Join(dir, "secrets-db.yaml")
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFiles(dir, files); err != nil {
+		t.Fatal(err)
+	}
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("file mode = %o, want 600", perm)
+	}
+
+	got, err := readFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, files) {
+		t.Fatalf("readFiles() = %v, want %v", got, files)
+	}
+}
+
+func TestWriteTarGzPrivate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.tar.gz")
+	files := map[string][]byte{"secrets-db.yaml": []byte("kind: Secret\n"), "services-web.yaml": []byte("kind: Service\n")}
+	if err := writeTarGz(path, files); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("archive mode = %o, want 600", perm)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if header.Mode != 0600 {
+			t.Fatalf("entry %s mode = %o, want 600", header.Name, header.Mode)
+		}
+	}
+
+	got, err := readTarGz(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, files) {
+		t.Fatalf("readTarGz() = %v, want %v", got, files)
+	}
+}
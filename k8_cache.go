@@ -0,0 +1,364 @@
+package go_k8_helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The kinds that can be cached
+const (
+	CachePods         = "pods"
+	CacheServices     = "services"
+	CacheDeployments  = "deployments"
+	CacheSecrets      = "secrets"
+	CacheStatefulSets = "statefulsets"
+	CacheDemonSets    = "daemonsets"
+)
+
+// cacheKinds maps the names and short names to the cached kinds
+var cacheKinds = map[string]string{
+	"pod": CachePods, "pods": CachePods, "po": CachePods,
+	"service": CacheServices, "services": CacheServices, "svc": CacheServices,
+	"deployment": CacheDeployments, "deployments": CacheDeployments, "deploy": CacheDeployments,
+	"secret": CacheSecrets, "secrets": CacheSecrets,
+	"statefulset": CacheStatefulSets, "statefulsets": CacheStatefulSets, "sts": CacheStatefulSets,
+	"daemonset": CacheDemonSets, "daemonsets": CacheDemonSets, "ds": CacheDemonSets,
+}
+
+// CacheStatus is the state of the local cache
+// Synced is true once the first list of every kind in every namespace has finished
+// LastEvent is the last time a change was received or the cache synced
+// LastError is the last watch error, the informers keep retrying after an error
+// Stale is true when the cache is not synced or a watch error happened after the last change
+type CacheStatus struct {
+	Running       bool      `json:"running" yaml:"running"`
+	Synced        bool      `json:"synced" yaml:"synced"`
+	Kinds         []string  `json:"kinds" yaml:"kinds"`
+	Namespaces    []string  `json:"namespaces" yaml:"namespaces"`
+	LastEvent     time.Time `json:"last_event" yaml:"last_event"`
+	LastError     string    `json:"last_error" yaml:"last_error"`
+	LastErrorTime time.Time `json:"last_error_time" yaml:"last_error_time"`
+	Stale         bool      `json:"stale" yaml:"stale"`
+}
+
+// informerCache holds the informers for the cached kinds and namespaces
+type informerCache struct {
+	mu              sync.Mutex
+	factories       map[string]informers.SharedInformerFactory
+	kinds           map[string]bool
+	stop            chan struct{}
+	last_event      time.Time
+	last_error      error
+	last_error_time time.Time
+}
+
+// StartCache starts informers so the list helpers answer from a local cache
+// GetPods, GetServices, GetDeployments, GetSecrets, GetStatefulSets and GetDemonSet use the cache
+// once the kind is synced, a field selector or an uncached kind or namespace goes to the server
+// namespaces: the namespaces to cache, empty caches all namespaces
+// kinds: the kinds to cache e.g. pods, svc or deploy, empty caches all the supported kinds
+// return: error
+func (m *K8) StartCache(namespaces []string, kinds ...string) error {
+	if m.cache.Load() != nil {
+		return errors.New("cache already started")
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	if len(kinds) == 0 {
+		kinds = []string{CachePods, CacheServices, CacheDeployments, CacheSecrets, CacheStatefulSets, CacheDemonSets}
+	}
+
+	c := &informerCache{
+		factories: map[string]informers.SharedInformerFactory{},
+		kinds:     map[string]bool{},
+		stop:      make(chan struct{}),
+	}
+	for _, kind := range kinds {
+		name, ok := cacheKinds[strings.ToLower(kind)]
+		if !ok {
+			return fmt.Errorf("kind %s cannot be cached", kind)
+		}
+		c.kinds[name] = true
+	}
+
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	for _, ns := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(client_set, 0, informers.WithNamespace(ns))
+		for kind := range c.kinds {
+			informer := cacheInformer(factory, kind)
+			if err := informer.SetWatchErrorHandler(c.watchError); err != nil {
+				return err
+			}
+			if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+				AddFunc:    func(interface{}) { c.event() },
+				UpdateFunc: func(interface{}, interface{}) { c.event() },
+				DeleteFunc: func(interface{}) { c.event() },
+			}); err != nil {
+				return err
+			}
+		}
+		c.factories[ns] = factory
+	}
+	if !m.cache.CompareAndSwap(nil, c) {
+		return errors.New("cache already started")
+	}
+	for _, factory := range c.factories {
+		factory.Start(c.stop)
+	}
+	return nil
+}
+
+// WaitForCacheSync waits until the cache has listed every kind
+// The helpers already use each kind once it is synced, this is only needed to wait for all of them
+// timeout: how long to wait
+// return: error if the cache is not started or did not sync in time
+func (m *K8) WaitForCacheSync(timeout time.Duration) error {
+	c := m.cache.Load()
+	if c == nil {
+		return errors.New("cache not started")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for ns, factory := range c.factories {
+		for informer_type, ok := range factory.WaitForCacheSync(ctx.Done()) {
+			if !ok {
+				return fmt.Errorf("cache for %s in Namespace(%s) did not sync", informer_type, ns)
+			}
+		}
+	}
+	c.event()
+	return nil
+}
+
+// StopCache stops the informers, the helpers go back to reading from the server
+func (m *K8) StopCache() {
+	c := m.cache.Swap(nil)
+	if c == nil {
+		return
+	}
+	close(c.stop)
+	for _, factory := range c.factories {
+		factory.Shutdown()
+	}
+}
+
+// CacheStatus returns the state of the local cache
+func (m *K8) CacheStatus() CacheStatus {
+	c := m.cache.Load()
+	if c == nil {
+		return CacheStatus{Stale: true}
+	}
+	synced := c.hasSynced()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := CacheStatus{
+		Running:       true,
+		Synced:        synced,
+		LastEvent:     c.last_event,
+		LastErrorTime: c.last_error_time,
+		Stale:         !synced || c.last_error_time.After(c.last_event),
+	}
+	if c.last_error != nil {
+		status.LastError = c.last_error.Error()
+	}
+	for kind := range c.kinds {
+		status.Kinds = append(status.Kinds, kind)
+	}
+	for ns := range c.factories {
+		status.Namespaces = append(status.Namespaces, ns)
+	}
+	return status
+}
+
+// hasSynced returns true once every informer has finished its first list
+func (c *informerCache) hasSynced() bool {
+	for _, factory := range c.factories {
+		for kind := range c.kinds {
+			if !cacheInformer(factory, kind).HasSynced() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// event records a change received by an informer
+func (c *informerCache) event() {
+	c.mu.Lock()
+	c.last_event = time.Now()
+	c.mu.Unlock()
+}
+
+// watchError records a watch error then logs it like the default handler
+func (c *informerCache) watchError(r *cache.Reflector, err error) {
+	c.mu.Lock()
+	c.last_error = err
+	c.last_error_time = time.Now()
+	c.mu.Unlock()
+	cache.DefaultWatchErrorHandler(r, err)
+}
+
+// cacheInformer returns the informer for a cached kind
+func cacheInformer(factory informers.SharedInformerFactory, kind string) cache.SharedIndexInformer {
+	switch kind {
+	case CacheServices:
+		return factory.Core().V1().Services().Informer()
+	case CacheDeployments:
+		return factory.Apps().V1().Deployments().Informer()
+	case CacheSecrets:
+		return factory.Core().V1().Secrets().Informer()
+	case CacheStatefulSets:
+		return factory.Apps().V1().StatefulSets().Informer()
+	case CacheDemonSets:
+		return factory.Apps().V1().DaemonSets().Informer()
+	}
+	return factory.Core().V1().Pods().Informer()
+}
+
+// cacheFactory finds the informer factory that can answer a list
+// kind: the cached kind
+// ns: namespace, empty for all namespaces
+// list_options: the list options
+// return: the factory, the label selector, false if the list must go to the server
+func (m *K8) cacheFactory(kind string, ns string, list_options metav1.ListOptions) (informers.SharedInformerFactory, labels.Selector, bool) {
+	c := m.cache.Load()
+	if c == nil || !c.kinds[kind] || list_options.FieldSelector != "" {
+		return nil, nil, false
+	}
+	factory, ok := c.factories[ns]
+	if !ok {
+		factory, ok = c.factories[metav1.NamespaceAll]
+	}
+	if !ok || !cacheInformer(factory, kind).HasSynced() {
+		return nil, nil, false
+	}
+	selector, err := labels.Parse(list_options.LabelSelector)
+	if err != nil {
+		return nil, nil, false
+	}
+	return factory, selector, true
+}
+
+// cachedPods lists pods from the cache
+// return: the pods, false if the cache cannot answer, error
+func (m *K8) cachedPods(ns string, list_options metav1.ListOptions) (*v1.PodList, bool, error) {
+	factory, selector, ok := m.cacheFactory(CachePods, ns, list_options)
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := factory.Core().V1().Pods().Lister().Pods(ns).List(selector)
+	if err != nil {
+		return nil, true, err
+	}
+	list := &v1.PodList{}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, true, nil
+}
+
+// cachedServices lists services from the cache
+// return: the services, false if the cache cannot answer, error
+func (m *K8) cachedServices(ns string, list_options metav1.ListOptions) (*v1.ServiceList, bool, error) {
+	factory, selector, ok := m.cacheFactory(CacheServices, ns, list_options)
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := factory.Core().V1().Services().Lister().Services(ns).List(selector)
+	if err != nil {
+		return nil, true, err
+	}
+	list := &v1.ServiceList{}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, true, nil
+}
+
+// cachedDeployments lists deployments from the cache
+// return: the deployments, false if the cache cannot answer, error
+func (m *K8) cachedDeployments(ns string, list_options metav1.ListOptions) (*appsv1.DeploymentList, bool, error) {
+	factory, selector, ok := m.cacheFactory(CacheDeployments, ns, list_options)
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := factory.Apps().V1().Deployments().Lister().Deployments(ns).List(selector)
+	if err != nil {
+		return nil, true, err
+	}
+	list := &appsv1.DeploymentList{}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, true, nil
+}
+
+// cachedSecrets lists secrets from the cache
+// return: the secrets, false if the cache cannot answer, error
+func (m *K8) cachedSecrets(ns string, list_options metav1.ListOptions) (*v1.SecretList, bool, error) {
+	factory, selector, ok := m.cacheFactory(CacheSecrets, ns, list_options)
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := factory.Core().V1().Secrets().Lister().Secrets(ns).List(selector)
+	if err != nil {
+		return nil, true, err
+	}
+	list := &v1.SecretList{}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, true, nil
+}
+
+// cachedStatefulSets lists statefulsets from the cache
+// return: the statefulsets, false if the cache cannot answer, error
+func (m *K8) cachedStatefulSets(ns string, list_options metav1.ListOptions) (*appsv1.StatefulSetList, bool, error) {
+	factory, selector, ok := m.cacheFactory(CacheStatefulSets, ns, list_options)
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := factory.Apps().V1().StatefulSets().Lister().StatefulSets(ns).List(selector)
+	if err != nil {
+		return nil, true, err
+	}
+	list := &appsv1.StatefulSetList{}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, true, nil
+}
+
+// cachedDemonSets lists demonsets from the cache
+// return: the demonsets, false if the cache cannot answer, error
+func (m *K8) cachedDemonSets(ns string, list_options metav1.ListOptions) (*appsv1.DaemonSetList, bool, error) {
+	factory, selector, ok := m.cacheFactory(CacheDemonSets, ns, list_options)
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := factory.Apps().V1().DaemonSets().Lister().DaemonSets(ns).List(selector)
+	if err != nil {
+		return nil, true, err
+	}
+	list := &appsv1.DaemonSetList{}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, true, nil
+}
@@ -0,0 +1,94 @@
+package go_k8_helm
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// startTestCache starts a cache of pods on a fake clientset
+func startTestCache(m *K8, objects ...*v1.Pod) *informerCache {
+	client_set := fake.NewSimpleClientset()
+	for _, pod := range objects {
+		client_set.Tracker().Add(pod)
+	}
+	c := &informerCache{
+		factories: map[string]informers.SharedInformerFactory{},
+		kinds:     map[string]bool{CachePods: true},
+		stop:      make(chan struct{}),
+	}
+	factory := informers.NewSharedInformerFactory(client_set, 0)
+	cacheInformer(factory, CachePods)
+	c.factories[metav1.NamespaceAll] = factory
+	m.cache.Store(c)
+	factory.Start(c.stop)
+	return c
+}
+
+func TestCacheUsedOnceSynced(t *testing.T) {
+	m := &K8{}
+	if _, ok, _ := m.cachedPods("default", metav1.ListOptions{}); ok {
+		t.Fatal("answered from a cache that is not started")
+	}
+
+	startTestCache(m, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}}})
+	defer m.StopCache()
+
+	// WaitForCacheSync is not called, the informer sync is enough
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		pods, ok, err := m.cachedPods("default", metav1.ListOptions{LabelSelector: "app=web"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			if len(pods.Items) != 1 || pods.Items[0].Name != "web" {
+				t.Fatalf("cachedPods() = %v", pods.Items)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the cache was not used after the informer synced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, ok, _ := m.cachedPods("default", metav1.ListOptions{FieldSelector: "spec.nodeName=a"}); ok {
+		t.Fatal("a field selector was answered from the cache")
+	}
+	if _, ok, _ := m.cachedServices("default", metav1.ListOptions{}); ok {
+		t.Fatal("an uncached kind was answered from the cache")
+	}
+	if status := m.CacheStatus(); !status.Running || !status.Synced {
+		t.Fatalf("CacheStatus() = %+v", status)
+	}
+}
+
+func TestStopCacheWhileReading(t *testing.T) {
+	m := &K8{}
+	startTestCache(m)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.cachedPods("default", metav1.ListOptions{})
+				m.CacheStatus()
+			}
+		}()
+	}
+	m.StopCache()
+	m.StopCache()
+	wg.Wait()
+
+	if status := m.CacheStatus(); status.Running {
+		t.Fatalf("CacheStatus() = %+v after StopCache", status)
+	}
+}
@@ -0,0 +1,418 @@
+package go_k8_helm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// MaxConfigMapSize is the largest size of the data in a configmap
+const MaxConfigMapSize = 1024 * 1024
+
+// ConfigMapHashAnnotation is the prefix of the pod template annotation that holds the configmap content hash
+// The prefix is followed by a short hash of the configmap name, see ConfigMapHashAnnotationKey
+const ConfigMapHashAnnotation = "go-k8-helm/configmap-hash-"
+
+// ConfigMapHashAnnotationKey returns the pod template annotation key for a configmap
+// A hash of the name is used so the key stays in the 63 character limit for any configmap name
+// name: name of the configmap
+// return: the annotation key
+func ConfigMapHashAnnotationKey(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return ConfigMapHashAnnotation + hex.EncodeToString(sum[:])[:16]
+}
+
+// ConfigMapSource adds keys to a configmap
+type ConfigMapSource func(*v1.ConfigMap) error
+
+// ConfigMapFromLiteral adds a key with a value
+// key: the key
+// value: the value
+func ConfigMapFromLiteral(key string, value string) ConfigMapSource {
+	return func(cm *v1.ConfigMap) error {
+		return addConfigMapKey(cm, key, []byte(value))
+	}
+}
+
+// ConfigMapFromFile adds a file using the file name as the key
+// Files that are not UTF-8 are added to binaryData
+// path: the file path
+func ConfigMapFromFile(path string) ConfigMapSource {
+	return ConfigMapFromFileKey(filepath.Base(path), path)
+}
+
+// ConfigMapFromFileKey adds a file with a key
+// Files that are not UTF-8 are added to binaryData
+// key: the key
+// path: the file path
+func ConfigMapFromFileKey(key string, path string) ConfigMapSource {
+	return func(cm *v1.ConfigMap) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return addConfigMapKey(cm, key, data)
+	}
+}
+
+// ConfigMapFromDir adds every file in a directory with one key per file
+// Symlinks to files are followed as in a mounted ConfigMap or Secret directory
+// Sub directories and files that are not valid keys are skipped
+// dir: the directory
+func ConfigMapFromDir(dir string) ConfigMapSource {
+	return func(cm *v1.ConfigMap) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			info, err := os.Stat(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				continue
+			}
+			if errs := validation.IsConfigMapKey(e.Name()); len(errs) > 0 {
+				log.Printf("Info: Skipping %s not a valid key\n", e.Name())
+				continue
+			}
+			if err := ConfigMapFromFile(filepath.Join(dir, e.Name()))(cm); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ConfigMapFromEnvFile adds the KEY=value lines of a .env file
+// Blank lines and lines starting with # are skipped, a line with only a key reads the value from the environment
+// path: the .env file path
+func ConfigMapFromEnvFile(path string) ConfigMapSource {
+	return func(cm *v1.ConfigMap) error {
+		return readEnvFile(path, func(key string, value []byte) error {
+			return addConfigMapKey(cm, key, value)
+		})
+	}
+}
+
+// readEnvFile reads the KEY=value lines of a .env file
+// Blank lines and lines starting with # are skipped, a line with only a key reads the value from the environment
+// path: the .env file path
+// add: called for each key
+// return: error
+func readEnvFile(path string, add func(key string, value []byte) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line_no := 0
+	for scanner.Scan() {
+		line_no++
+		line := strings.TrimSpace(scanner.Text())
+		if line_no == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found {
+			value = os.Getenv(key)
+		}
+		if err := add(key, []byte(value)); err != nil {
+			return fmt.Errorf("%s line %d: %w", path, line_no, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// addConfigMapKey adds a key to data or to binaryData when the value is not UTF-8
+func addConfigMapKey(cm *v1.ConfigMap, key string, value []byte) error {
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		return fmt.Errorf("invalid key %s: %s", key, strings.Join(errs, ", "))
+	}
+	if _, found := cm.Data[key]; found {
+		return fmt.Errorf("duplicate key %s", key)
+	}
+	if _, found := cm.BinaryData[key]; found {
+		return fmt.Errorf("duplicate key %s", key)
+	}
+	if utf8.Valid(value) {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = string(value)
+		return nil
+	}
+	if cm.BinaryData == nil {
+		cm.BinaryData = map[string][]byte{}
+	}
+	cm.BinaryData[key] = value
+	return nil
+}
+
+// NewConfigMap builds a configmap from literals, files, directories and .env files
+// e.g. NewConfigMap("default", "web", ConfigMapFromLiteral("mode", "prod"), ConfigMapFromDir("./conf"))
+// ns: namespace
+// name: name of the configmap
+// sources: the sources of the keys
+// return: *v1.ConfigMap, error
+func NewConfigMap(ns string, name string, sources ...ConfigMapSource) (*v1.ConfigMap, error) {
+	cm := &v1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+	}
+	for _, source := range sources {
+		if err := source(cm); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkConfigMapSize(cm); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// checkConfigMapSize returns an error when the data is over MaxConfigMapSize
+func checkConfigMapSize(cm *v1.ConfigMap) error {
+	size := 0
+	for k, v := range cm.Data {
+		size += len(k) + len(v)
+	}
+	for k, v := range cm.BinaryData {
+		size += len(k) + len(v)
+	}
+	if size > MaxConfigMapSize {
+		return fmt.Errorf("configmap %s is %d bytes which is over the %d byte limit", cm.Name, size, MaxConfigMapSize)
+	}
+	return nil
+}
+
+// configMapHash returns a hash of the configmap data
+func configMapHash(cm *v1.ConfigMap) string {
+	h := sha256.New()
+	var keys []string
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\n", k, cm.Data[k])
+	}
+	keys = nil
+	for k := range cm.BinaryData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=", k)
+		h.Write(cm.BinaryData[k])
+		h.Write([]byte("\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GetConfigMaps gets configmaps from a k8 cluster
+// ns: namespace
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: v1.ConfigMapList, error
+func (m *K8) GetConfigMaps(ns string, opts ...ListOption) (*v1.ConfigMapList, error) {
+
+	//**********************
+	// creates the clientset
+	//**********************
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return client_set.CoreV1().ConfigMaps(ns).List(context.TODO(), list_options)
+}
+
+// GetConfigMap gets a configmap from a k8 cluster
+// ns: namespace
+// name: name of the configmap
+// return: *v1.ConfigMap, error
+func (m *K8) GetConfigMap(ns string, name string) (*v1.ConfigMap, error) {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	return client_set.CoreV1().ConfigMaps(ns).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+// CreateConfigMap creates a configmap in a k8 cluster
+// cm: the configmap e.g. from NewConfigMap
+// return: error
+func (m *K8) CreateConfigMap(cm *v1.ConfigMap) error {
+	if err := checkConfigMapSize(cm); err != nil {
+		return err
+	}
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	_, err = client_set.CoreV1().ConfigMaps(cm.Namespace).Create(context.TODO(), cm, metav1.CreateOptions{
+		DryRun: m.dryRunOptions(),
+	})
+	return err
+}
+
+// UpdateConfigMap replaces the data of an existing configmap
+// cm: the configmap e.g. from NewConfigMap
+// roll_deployments: if true add the content hash to the deployments that use the configmap so they roll
+// return: error
+func (m *K8) UpdateConfigMap(cm *v1.ConfigMap, roll_deployments bool) error {
+	if err := checkConfigMapSize(cm); err != nil {
+		return err
+	}
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	client := client_set.CoreV1().ConfigMaps(cm.Namespace)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := client.Get(context.TODO(), cm.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Data = cm.Data
+		current.BinaryData = cm.BinaryData
+		for k, v := range cm.Labels {
+			metav1.SetMetaDataLabel(&current.ObjectMeta, k, v)
+		}
+		for k, v := range cm.Annotations {
+			metav1.SetMetaDataAnnotation(&current.ObjectMeta, k, v)
+		}
+		_, err = client.Update(context.TODO(), current, metav1.UpdateOptions{
+			DryRun: m.dryRunOptions(),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if roll_deployments {
+		return m.rollConfigMapDeployments(cm)
+	}
+	return nil
+}
+
+// UpsertConfigMap creates the configmap or updates it when it exists
+// cm: the configmap e.g. from NewConfigMap
+// roll_deployments: if true add the content hash to the deployments that use the configmap so they roll
+// return: error
+func (m *K8) UpsertConfigMap(cm *v1.ConfigMap, roll_deployments bool) error {
+	err := m.CreateConfigMap(cm)
+	if apierrors.IsAlreadyExists(err) {
+		return m.UpdateConfigMap(cm, roll_deployments)
+	}
+	return err
+}
+
+// DeleteConfigMap deletes a configmap from a k8 cluster
+// ns: namespace
+// name: name of the configmap
+// opts: delete options e.g. OptionDeleteWait
+// return: error
+func (m *K8) DeleteConfigMap(ns string, name string, opts ...DeleteOption) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	client := client_set.CoreV1().ConfigMaps(ns)
+	return deleteAndWait(name, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
+}
+
+// rollConfigMapDeployments sets the content hash annotation on the pod template
+// of every deployment in the namespace that uses the configmap
+// cm: the configmap
+// return: error
+func (m *K8) rollConfigMapDeployments(cm *v1.ConfigMap) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	client := client_set.AppsV1().Deployments(cm.Namespace)
+	deployments, err := client.List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	hash := configMapHash(cm)
+	annotation := ConfigMapHashAnnotationKey(cm.Name)
+	for _, d := range deployments.Items {
+		if !usesConfigMap(&d.Spec.Template.Spec, cm.Name) || d.Spec.Template.Annotations[annotation] == hash {
+			continue
+		}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			current, err := client.Get(context.TODO(), d.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			metav1.SetMetaDataAnnotation(&current.Spec.Template.ObjectMeta, annotation, hash)
+			_, err = client.Update(context.TODO(), current, metav1.UpdateOptions{
+				DryRun: m.dryRunOptions(),
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("deployment %s: %w", d.Name, err)
+		}
+		log.Printf("Info: Rolling Deployment(%s) for ConfigMap(%s)\n", d.Name, cm.Name)
+	}
+	return nil
+}
+
+// usesConfigMap returns true if a pod spec mounts or reads the configmap
+func usesConfigMap(spec *v1.PodSpec, name string) bool {
+	for _, vol := range spec.Volumes {
+		if vol.ConfigMap != nil && vol.ConfigMap.Name == name {
+			return true
+		}
+		if vol.Projected != nil {
+			for _, source := range vol.Projected.Sources {
+				if source.ConfigMap != nil && source.ConfigMap.Name == name {
+					return true
+				}
+			}
+		}
+	}
+	containers := append(append([]v1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, c := range containers {
+		for _, env := range c.EnvFrom {
+			if env.ConfigMapRef != nil && env.ConfigMapRef.Name == name {
+				return true
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil && env.ValueFrom.ConfigMapKeyRef.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
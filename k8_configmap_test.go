@@ -0,0 +1,156 @@
+package go_k8_helm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	t.Setenv("GO_K8_HELM_TEST_FROM_ENV", "from-env")
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "pairs", data: "A=1\nB=two words\n", want: map[string]string{"A": "1", "B": "two words"}},
+		{name: "comments and blank lines", data: "# comment\n\nA=1\n  # indented comment\n", want: map[string]string{"A": "1"}},
+		{name: "value with equals", data: "URL=http://x?a=b\n", want: map[string]string{"URL": "http://x?a=b"}},
+		{name: "spaces around key", data: "  A =1\n", want: map[string]string{"A": "1"}},
+		{name: "empty value", data: "A=\n", want: map[string]string{"A": ""}},
+		{name: "key from environment", data: "GO_K8_HELM_TEST_FROM_ENV\n", want: map[string]string{"GO_K8_HELM_TEST_FROM_ENV": "from-env"}},
+		{name: "byte order mark", data: "\ufeffA=1\n", want: map[string]string{"A": "1"}},
+		{name: "crlf", data: "A=1\r\nB=2\r\n", want: map[string]string{"A": "1", "B": "2"}},
+		{name: "invalid key", data: "A=1\nbad key=2\n", wantErr: true},
+		{name: "duplicate key", data: "A=1\nA=2\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.env")
+			if err := os.WriteFile(path, []byte(tt.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+			cm := &v1.ConfigMap{}
+			err := readEnvFile(path, func(key string, value []byte) error {
+				return addConfigMapKey(cm, key, value)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readEnvFile() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(cm.Data, tt.want) {
+				t.Fatalf("readEnvFile() = %v, want %v", cm.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddConfigMapKeyBinary(t *testing.T) {
+	cm := &v1.ConfigMap{}
+	if err := addConfigMapKey(cm, "logo.png", []byte{0x89, 0x50, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+	if err := addConfigMapKey(cm, "mode", []byte("prod")); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := cm.BinaryData["logo.png"]; !found || cm.Data["mode"] != "prod" {
+		t.Fatalf("configmap = %+v", cm)
+	}
+	if err := addConfigMapKey(cm, "logo.png", []byte("x")); err == nil {
+		t.Fatal("a duplicate binary key was added")
+	}
+}
+
+func TestConfigMapHashAnnotationKey(t *testing.T) {
+	long := strings.Repeat("a", 253)
+	for _, name := range []string{"web", long} {
+		key := ConfigMapHashAnnotationKey(name)
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			t.Fatalf("ConfigMapHashAnnotationKey(%d characters) = %s: %v", len(name), key, errs)
+		}
+	}
+	if ConfigMapHashAnnotationKey("web") == ConfigMapHashAnnotationKey("api") {
+		t.Fatal("two configmaps have the same annotation key")
+	}
+}
+
+func TestConfigMapHash(t *testing.T) {
+	a := &v1.ConfigMap{Data: map[string]string{"a": "1", "b": "2"}}
+	b := &v1.ConfigMap{Data: map[string]string{"b": "2", "a": "1"}}
+	c := &v1.ConfigMap{Data: map[string]string{"a": "1", "b": "3"}}
+	if configMapHash(a) != configMapHash(b) {
+		t.Fatal("the hash depends on the key order")
+	}
+	if configMapHash(a) == configMapHash(c) {
+		t.Fatal("the hash did not change with the data")
+	}
+}
+
+func TestUsesConfigMap(t *testing.T) {
+	tests := []struct {
+		name string
+		spec v1.PodSpec
+		want bool
+	}{
+		{name: "volume", spec: v1.PodSpec{Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "web"}}}}}}, want: true},
+		{name: "projected", spec: v1.PodSpec{Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{Sources: []v1.VolumeProjection{{ConfigMap: &v1.ConfigMapProjection{LocalObjectReference: v1.LocalObjectReference{Name: "web"}}}}}}}}}, want: true},
+		{name: "env from in init container", spec: v1.PodSpec{InitContainers: []v1.Container{{EnvFrom: []v1.EnvFromSource{{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "web"}}}}}}}, want: true},
+		{name: "env key", spec: v1.PodSpec{Containers: []v1.Container{{Env: []v1.EnvVar{{Name: "A", ValueFrom: &v1.EnvVarSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "web"}, Key: "a"}}}}}}}, want: true},
+		{name: "other configmap", spec: v1.PodSpec{Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "api"}}}}}}},
+		{name: "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usesConfigMap(&tt.spec, "web"); got != tt.want {
+				t.Fatalf("usesConfigMap() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigMapFromDir(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "..2023_01_01")
+	if err := os.Mkdir(data, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"app.conf": "a=1", "mode": "prod"} {
+		if err := os.WriteFile(filepath.Join(data, name), []byte(value), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the layout of a mounted ConfigMap directory
+	links := map[string]string{"..data": "..2023_01_01", "app.conf": "..data/app.conf", "mode": "..data/mode"}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plain.txt"), []byte("plain"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := &v1.ConfigMap{}
+	if err := ConfigMapFromDir(dir)(cm); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"app.conf": "a=1", "mode": "prod", "plain.txt": "plain"}
+	if !reflect.DeepEqual(cm.Data, want) {
+		t.Fatalf("ConfigMapFromDir() = %v, want %v", cm.Data, want)
+	}
+
+	if err := os.Symlink("missing", filepath.Join(dir, "broken")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConfigMapFromDir(dir)(&v1.ConfigMap{}); err == nil {
+		t.Fatal("a broken symlink was skipped")
+	}
+}
@@ -0,0 +1,228 @@
+package go_k8_helm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// CronJobSuspendAnnotation records the suspend state of a cronjob before it was suspended
+const CronJobSuspendAnnotation = "go-k8-helm/suspended-before"
+
+// GetCronJobs gets cronjobs from a k8 cluster
+// ns: namespace
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: batchv1.CronJobList, error
+func (m *K8) GetCronJobs(ns string, opts ...ListOption) (*batchv1.CronJobList, error) {
+
+	//**********************
+	// creates the clientset
+	//**********************
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return client_set.BatchV1().CronJobs(ns).List(context.TODO(), list_options)
+}
+
+// GetCronJob gets a cronjob from a k8 cluster
+// ns: namespace
+// name: name of the cronjob
+// return: *batchv1.CronJob, error
+func (m *K8) GetCronJob(ns string, name string) (*batchv1.CronJob, error) {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	return client_set.BatchV1().CronJobs(ns).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+// DeleteCronJob deletes a cronjob from a k8 cluster
+// ns: namespace
+// name: name of the cronjob
+// opts: delete options e.g. OptionDeleteWait
+// return: error
+func (m *K8) DeleteCronJob(ns string, name string, opts ...DeleteOption) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	client := client_set.BatchV1().CronJobs(ns)
+	return deleteAndWait(name, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
+}
+
+// SuspendCronJob suspends a cronjob and records its state so ResumeCronJob can restore it
+// ns: namespace
+// name: name of the cronjob
+// return: error
+func (m *K8) SuspendCronJob(ns string, name string) error {
+	_, err := m.setCronJobSuspend(ns, name, true, false)
+	return err
+}
+
+// ResumeCronJob restores the suspend state recorded by SuspendCronJob
+// A cronjob without a recorded state is resumed
+// ns: namespace
+// name: name of the cronjob
+// return: error
+func (m *K8) ResumeCronJob(ns string, name string) error {
+	_, err := m.setCronJobSuspend(ns, name, false, false)
+	return err
+}
+
+// SuspendCronJobs suspends the cronjobs in a namespace and records their state
+// ns: namespace
+// opts: list options to select the cronjobs, none selects all the cronjobs in the namespace
+// return: the names of the cronjobs that were changed, error
+func (m *K8) SuspendCronJobs(ns string, opts ...ListOption) ([]string, error) {
+	return m.setCronJobsSuspend(ns, true, opts)
+}
+
+// ResumeCronJobs restores the state of the cronjobs suspended by SuspendCronJobs or SuspendCronJob
+// Cronjobs without a recorded state are left as they are
+// ns: namespace
+// opts: list options to select the cronjobs, none selects all the cronjobs in the namespace
+// return: the names of the cronjobs that were changed, error
+func (m *K8) ResumeCronJobs(ns string, opts ...ListOption) ([]string, error) {
+	return m.setCronJobsSuspend(ns, false, opts)
+}
+
+// setCronJobsSuspend suspends or resumes the selected cronjobs
+func (m *K8) setCronJobsSuspend(ns string, suspend bool, opts []ListOption) ([]string, error) {
+	cron_jobs, err := m.GetCronJobs(ns, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, c := range cron_jobs.Items {
+		updated, err := m.setCronJobSuspend(ns, c.Name, suspend, !suspend)
+		if err != nil {
+			return changed, fmt.Errorf("cronjob %s: %w", c.Name, err)
+		}
+		if updated {
+			changed = append(changed, c.Name)
+		}
+	}
+	return changed, nil
+}
+
+// setCronJobSuspend suspends or resumes a cronjob
+// ns: namespace
+// name: name of the cronjob
+// suspend: true to suspend, false to restore the recorded state
+// recorded_only: only resume when a state was recorded
+// return: true if the cronjob was changed, error
+func (m *K8) setCronJobSuspend(ns string, name string, suspend bool, recorded_only bool) (bool, error) {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return false, err
+	}
+	client := client_set.BatchV1().CronJobs(ns)
+
+	changed := false
+	value := suspend
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cron_job, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current := cron_job.Spec.Suspend != nil && *cron_job.Spec.Suspend
+		before, recorded := cron_job.Annotations[CronJobSuspendAnnotation]
+
+		if suspend {
+			if !recorded {
+				metav1.SetMetaDataAnnotation(&cron_job.ObjectMeta, CronJobSuspendAnnotation, strconv.FormatBool(current))
+			}
+			if current && recorded {
+				return nil
+			}
+			cron_job.Spec.Suspend = &suspend
+		} else {
+			if !recorded && recorded_only {
+				return nil
+			}
+			restore := false
+			if recorded {
+				restore, _ = strconv.ParseBool(before)
+				delete(cron_job.Annotations, CronJobSuspendAnnotation)
+			}
+			if !recorded && current == restore {
+				return nil
+			}
+			cron_job.Spec.Suspend = &restore
+			value = restore
+		}
+
+		_, err = client.Update(context.TODO(), cron_job, metav1.UpdateOptions{DryRun: m.dryRunOptions()})
+		changed = err == nil
+		return err
+	})
+	if changed {
+		log.Printf("Info: Set CronJob(%s) suspend to %t in Namespace(%s)\n", name, value, ns)
+	}
+	return changed, err
+}
+
+// TriggerCronJob creates a job from a cronjob now like kubectl create job --from=cronjob/name
+// Use WaitForJob to wait for the job
+// ns: namespace
+// name: name of the cronjob
+// return: the created job, error
+func (m *K8) TriggerCronJob(ns string, name string) (*batchv1.Job, error) {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	cron_job, err := client_set.BatchV1().CronJobs(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// job names are used as a label value so keep them to 63 characters
+	suffix := fmt.Sprintf("-manual-%d", time.Now().Unix())
+	job_name := cron_job.Name
+	if len(job_name)+len(suffix) > 63 {
+		job_name = job_name[:63-len(suffix)]
+	}
+	job_name += suffix
+
+	annotations := map[string]string{"cronjob.kubernetes.io/instantiate": "manual"}
+	for k, v := range cron_job.Spec.JobTemplate.Annotations {
+		annotations[k] = v
+	}
+	controller := true
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        job_name,
+			Namespace:   ns,
+			Labels:      cron_job.Spec.JobTemplate.Labels,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: batchv1.SchemeGroupVersion.String(),
+				Kind:       "CronJob",
+				Name:       cron_job.Name,
+				UID:        cron_job.UID,
+				Controller: &controller,
+			}},
+		},
+		Spec: cron_job.Spec.JobTemplate.Spec,
+	}
+	created, err := client_set.BatchV1().Jobs(ns).Create(context.TODO(), job, metav1.CreateOptions{DryRun: m.dryRunOptions()})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Info: Triggered Job(%s) from CronJob(%s) in Namespace(%s)\n", created.Name, name, ns)
+	return created, nil
+}
@@ -0,0 +1,228 @@
+package go_k8_helm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// DeleteOptions are the options used when deleting a resource
+// PropagationPolicy is Foreground, Background or Orphan, empty uses the server default
+// GracePeriodSeconds is the grace period, nil uses the default for the kind
+// PreconditionUID and PreconditionResourceVersion only delete the resource if they match
+// IgnoreNotFound returns no error if the resource does not exist
+// Wait blocks until the resource is gone or the Timeout is reached
+// WaitDependents also waits for the dependents by using Foreground propagation
+type DeleteOptions struct {
+	PropagationPolicy           metav1.DeletionPropagation
+	GracePeriodSeconds          *int64
+	PreconditionUID             string
+	PreconditionResourceVersion string
+	IgnoreNotFound              bool
+	Wait                        bool
+	WaitDependents              bool
+	Timeout                     time.Duration
+}
+
+// DeleteOption is the option for a delete
+type DeleteOption func(*DeleteOptions)
+
+// OptionDeletePropagation is the option for the propagation policy
+// policy: metav1.DeletePropagationForeground, metav1.DeletePropagationBackground or metav1.DeletePropagationOrphan
+func OptionDeletePropagation(policy metav1.DeletionPropagation) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.PropagationPolicy = policy
+	}
+}
+
+// OptionDeleteGracePeriod is the option for the grace period in seconds
+func OptionDeleteGracePeriod(seconds int64) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.GracePeriodSeconds = &seconds
+	}
+}
+
+// OptionDeletePreconditionUID is the option to only delete the resource with this UID
+func OptionDeletePreconditionUID(uid string) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.PreconditionUID = uid
+	}
+}
+
+// OptionDeletePreconditionResourceVersion is the option to only delete the resource at this resourceVersion
+func OptionDeletePreconditionResourceVersion(resource_version string) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.PreconditionResourceVersion = resource_version
+	}
+}
+
+// OptionDeleteIgnoreNotFound is the option to ignore a resource that does not exist
+func OptionDeleteIgnoreNotFound(ignore bool) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.IgnoreNotFound = ignore
+	}
+}
+
+// OptionDeleteWait is the option to wait until the resource is gone
+// timeout: how long to wait, 0 waits for 5 minutes
+func OptionDeleteWait(timeout time.Duration) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.Wait = true
+		o.Timeout = timeout
+	}
+}
+
+// OptionDeleteWaitDependents is the option to wait until the resource and its dependents are gone
+// Foreground propagation is used, an explicit background or orphan propagation is an error
+// timeout: how long to wait, 0 waits for 5 minutes
+func OptionDeleteWaitDependents(timeout time.Duration) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.Wait = true
+		o.WaitDependents = true
+		o.Timeout = timeout
+	}
+}
+
+// newDeleteOptions builds the delete options
+// defaults: the options to use before the caller options
+// opts: the caller options
+// return: *DeleteOptions, error if the options conflict
+func newDeleteOptions(defaults []DeleteOption, opts []DeleteOption) (*DeleteOptions, error) {
+	o := &DeleteOptions{}
+	for _, opt := range defaults {
+		opt(o)
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.WaitDependents {
+		// The owner is only removed once its dependents are gone
+		switch o.PropagationPolicy {
+		case "", metav1.DeletePropagationForeground:
+			o.PropagationPolicy = metav1.DeletePropagationForeground
+		default:
+			return nil, fmt.Errorf("cannot wait for dependents with %s propagation, use foreground", o.PropagationPolicy)
+		}
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 5 * time.Minute
+	}
+	return o, nil
+}
+
+// metaDeleteOptions converts the options to the k8 delete options
+func (o *DeleteOptions) metaDeleteOptions() metav1.DeleteOptions {
+	delete_options := metav1.DeleteOptions{
+		GracePeriodSeconds: o.GracePeriodSeconds,
+	}
+	if o.PropagationPolicy != "" {
+		policy := o.PropagationPolicy
+		delete_options.PropagationPolicy = &policy
+	}
+	if o.PreconditionUID != "" || o.PreconditionResourceVersion != "" {
+		delete_options.Preconditions = &metav1.Preconditions{}
+		if o.PreconditionUID != "" {
+			uid := types.UID(o.PreconditionUID)
+			delete_options.Preconditions.UID = &uid
+		}
+		if o.PreconditionResourceVersion != "" {
+			rv := o.PreconditionResourceVersion
+			delete_options.Preconditions.ResourceVersion = &rv
+		}
+	}
+	return delete_options
+}
+
+// deleteAndWait deletes a resource using the options and waits for it to be gone if asked
+// When waiting the UID is recorded before the delete and only that object is deleted,
+// the resource is gone once it is not found or has a different UID e.g. it was created again
+// name: name of the resource
+// del: deletes the resource
+// get: gets the UID of the resource, see deleteAndWaitGetter
+// defaults: the options to use before the caller options
+// opts: the caller options
+// return: error
+func deleteAndWait(name string, del func(ctx context.Context, name string, opts metav1.DeleteOptions) error, get func(ctx context.Context, name string) (types.UID, error), defaults []DeleteOption, opts []DeleteOption) error {
+	o, err := newDeleteOptions(defaults, opts)
+	if err != nil {
+		return err
+	}
+
+	uid := types.UID(o.PreconditionUID)
+	if o.Wait && uid == "" {
+		uid, err = get(context.TODO(), name)
+		if apierrors.IsNotFound(err) {
+			if o.IgnoreNotFound {
+				return nil
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		// delete the object that is waited for, not one created in the meantime
+		o.PreconditionUID = string(uid)
+	}
+
+	err = del(context.TODO(), name, o.metaDeleteOptions())
+	if apierrors.IsNotFound(err) && o.IgnoreNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !o.Wait {
+		return nil
+	}
+	err = wait.PollImmediate(time.Second, o.Timeout, func() (bool, error) {
+		current, err := get(context.TODO(), name)
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return current != uid, nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for %s to be deleted: %w", name, err)
+	}
+	return nil
+}
+
+// deleteAndWaitGetter returns the UID lookup used by deleteAndWait from a client Get
+// get: the Get of a typed or dynamic client e.g. client_set.CoreV1().Pods(ns).Get
+// return: gets the UID of the resource by name
+func deleteAndWaitGetter[T metav1.Object](get func(ctx context.Context, name string, opts metav1.GetOptions) (T, error)) func(ctx context.Context, name string) (types.UID, error) {
+	return func(ctx context.Context, name string) (types.UID, error) {
+		obj, err := get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return obj.GetUID(), nil
+	}
+}
+
+// dynamicDeleteAndWait deletes a resource with the dynamic client using the options
+// dr: the resource interface
+// name: name of the resource
+// defaults: the options to use before the caller options
+// opts: the caller options
+// return: error
+func dynamicDeleteAndWait(dr dynamic.ResourceInterface, name string, defaults []DeleteOption, opts []DeleteOption) error {
+	del := func(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+		return dr.Delete(ctx, name, opts)
+	}
+	get := func(ctx context.Context, name string, opts metav1.GetOptions) (*unstructured.Unstructured, error) {
+		return dr.Get(ctx, name, opts)
+	}
+	return deleteAndWait(name, del, deleteAndWaitGetter(get), defaults, opts)
+}
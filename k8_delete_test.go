@@ -0,0 +1,160 @@
+package go_k8_helm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestNewDeleteOptions(t *testing.T) {
+	foreground := []DeleteOption{OptionDeletePropagation(metav1.DeletePropagationForeground)}
+	tests := []struct {
+		name            string
+		defaults        []DeleteOption
+		opts            []DeleteOption
+		wantPropagation metav1.DeletionPropagation
+		wantWait        bool
+		wantTimeout     time.Duration
+		wantErr         bool
+	}{
+		{name: "empty", wantTimeout: 5 * time.Minute},
+		{name: "default used", defaults: foreground, wantPropagation: metav1.DeletePropagationForeground, wantTimeout: 5 * time.Minute},
+		{
+			name:            "caller overrides default",
+			defaults:        foreground,
+			opts:            []DeleteOption{OptionDeletePropagation(metav1.DeletePropagationOrphan)},
+			wantPropagation: metav1.DeletePropagationOrphan,
+			wantTimeout:     5 * time.Minute,
+		},
+		{name: "wait with timeout", opts: []DeleteOption{OptionDeleteWait(time.Minute)}, wantWait: true, wantTimeout: time.Minute},
+		{
+			name:            "wait dependents uses foreground",
+			opts:            []DeleteOption{OptionDeleteWaitDependents(0)},
+			wantPropagation: metav1.DeletePropagationForeground,
+			wantWait:        true,
+			wantTimeout:     5 * time.Minute,
+		},
+		{
+			name:            "wait dependents with foreground",
+			opts:            []DeleteOption{OptionDeletePropagation(metav1.DeletePropagationForeground), OptionDeleteWaitDependents(time.Minute)},
+			wantPropagation: metav1.DeletePropagationForeground,
+			wantWait:        true,
+			wantTimeout:     time.Minute,
+		},
+		{
+			name:    "wait dependents with background",
+			opts:    []DeleteOption{OptionDeletePropagation(metav1.DeletePropagationBackground), OptionDeleteWaitDependents(0)},
+			wantErr: true,
+		},
+		{
+			name:    "wait dependents with orphan",
+			opts:    []DeleteOption{OptionDeletePropagation(metav1.DeletePropagationOrphan), OptionDeleteWaitDependents(0)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := newDeleteOptions(tt.defaults, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newDeleteOptions() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if o.PropagationPolicy != tt.wantPropagation || o.Wait != tt.wantWait || o.Timeout != tt.wantTimeout {
+				t.Fatalf("newDeleteOptions() = %+v", o)
+			}
+		})
+	}
+}
+
+func TestMetaDeleteOptions(t *testing.T) {
+	o, err := newDeleteOptions(nil, []DeleteOption{
+		OptionDeleteGracePeriod(0),
+		OptionDeletePreconditionUID("uid-1"),
+		OptionDeletePreconditionResourceVersion("42"),
+		OptionDeletePropagation(metav1.DeletePropagationBackground),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := o.metaDeleteOptions()
+	if got.GracePeriodSeconds == nil || *got.GracePeriodSeconds != 0 {
+		t.Fatalf("GracePeriodSeconds = %v", got.GracePeriodSeconds)
+	}
+	if got.PropagationPolicy == nil || *got.PropagationPolicy != metav1.DeletePropagationBackground {
+		t.Fatalf("PropagationPolicy = %v", got.PropagationPolicy)
+	}
+	if got.Preconditions == nil || *got.Preconditions.UID != "uid-1" || *got.Preconditions.ResourceVersion != "42" {
+		t.Fatalf("Preconditions = %+v", got.Preconditions)
+	}
+
+	if got := (&DeleteOptions{}).metaDeleteOptions(); got.PropagationPolicy != nil || got.Preconditions != nil {
+		t.Fatalf("empty options = %+v", got)
+	}
+}
+
+func TestDeleteAndWait(t *testing.T) {
+	not_found := apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "web")
+	tests := []struct {
+		name    string
+		uids    []types.UID
+		opts    []DeleteOption
+		delErr  error
+		wantErr bool
+	}{
+		{name: "gone", uids: []types.UID{"a", ""}, opts: []DeleteOption{OptionDeleteWait(time.Minute)}},
+		{name: "created again", uids: []types.UID{"a", "b"}, opts: []DeleteOption{OptionDeleteWait(time.Minute)}},
+		{name: "not waiting", opts: nil},
+		{name: "missing", uids: []types.UID{""}, opts: []DeleteOption{OptionDeleteWait(time.Minute)}, wantErr: true},
+		{name: "missing ignored", uids: []types.UID{""}, opts: []DeleteOption{OptionDeleteWait(time.Minute), OptionDeleteIgnoreNotFound(true)}},
+		{name: "delete not found ignored", delErr: not_found, opts: []DeleteOption{OptionDeleteIgnoreNotFound(true)}},
+		{name: "delete not found", delErr: not_found, wantErr: true},
+		{name: "still there", uids: []types.UID{"a", "a"}, opts: []DeleteOption{OptionDeleteWait(time.Millisecond)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			get := func(ctx context.Context, name string) (types.UID, error) {
+				uid := tt.uids[len(tt.uids)-1]
+				if calls < len(tt.uids) {
+					uid = tt.uids[calls]
+				}
+				calls++
+				if uid == "" {
+					return "", not_found
+				}
+				return uid, nil
+			}
+			var precondition *types.UID
+			del := func(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+				if opts.Preconditions != nil {
+					precondition = opts.Preconditions.UID
+				}
+				return tt.delErr
+			}
+			err := deleteAndWait("web", del, get, nil, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deleteAndWait() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && len(tt.uids) > 0 && tt.uids[0] != "" && (precondition == nil || *precondition != tt.uids[0]) {
+				t.Fatalf("the delete was not limited to uid %s", tt.uids[0])
+			}
+		})
+	}
+}
+
+func TestDeleteAndWaitGetter(t *testing.T) {
+	get := deleteAndWaitGetter(func(ctx context.Context, name string, opts metav1.GetOptions) (*metav1.ObjectMeta, error) {
+		return &metav1.ObjectMeta{Name: name, UID: "uid-" + types.UID(name)}, nil
+	})
+	uid, err := get(context.TODO(), "web")
+	if err != nil || uid != "uid-web" {
+		t.Fatalf("get() = %s, %v", uid, err)
+	}
+}
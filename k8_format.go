@@ -0,0 +1,361 @@
+package go_k8_helm
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+// OutputFormat is the format used by a Formatter
+type OutputFormat string
+
+// The output formats
+const (
+	OutputTable    OutputFormat = "table"
+	OutputJSON     OutputFormat = "json"
+	OutputYAML     OutputFormat = "yaml"
+	OutputCSV      OutputFormat = "csv"
+	OutputJSONPath OutputFormat = "jsonpath"
+	OutputTemplate OutputFormat = "go-template"
+)
+
+// Column is a column in table or csv output
+// Header is the column title
+// Path is a JSONPath to the value in each item e.g. .metadata.name or {.spec.ports[*].port}
+type Column struct {
+	Header string `json:"header" yaml:"header"`
+	Path   string `json:"path" yaml:"path"`
+}
+
+// Formatter renders list results, objects or slices such as []ServiceDetails
+// Columns are used by table and csv output, when empty the columns are picked for the kind
+// Template is the JSONPath or Go template expression
+// NoHeaders leaves out the header row
+type Formatter struct {
+	Format    OutputFormat `json:"format" yaml:"format"`
+	Columns   []Column     `json:"columns" yaml:"columns"`
+	Template  string       `json:"template" yaml:"template"`
+	NoHeaders bool         `json:"no_headers" yaml:"no_headers"`
+}
+
+// defaultColumns are the table columns for each kind
+var defaultColumns = map[string][]Column{
+	"Pod": {
+		{Header: "NAME", Path: ".metadata.name"},
+		{Header: "NAMESPACE", Path: ".metadata.namespace"},
+		{Header: "STATUS", Path: ".status.phase"},
+		{Header: "IP", Path: ".status.podIP"},
+		{Header: "NODE", Path: ".spec.nodeName"},
+	},
+	"Service": {
+		{Header: "NAME", Path: ".metadata.name"},
+		{Header: "NAMESPACE", Path: ".metadata.namespace"},
+		{Header: "TYPE", Path: ".spec.type"},
+		{Header: "CLUSTER-IP", Path: ".spec.clusterIP"},
+		{Header: "PORTS", Path: ".spec.ports[*].port"},
+	},
+	"Deployment": {
+		{Header: "NAME", Path: ".metadata.name"},
+		{Header: "NAMESPACE", Path: ".metadata.namespace"},
+		{Header: "DESIRED", Path: ".spec.replicas"},
+		{Header: "READY", Path: ".status.readyReplicas"},
+		{Header: "AVAILABLE", Path: ".status.availableReplicas"},
+	},
+	"StatefulSet": {
+		{Header: "NAME", Path: ".metadata.name"},
+		{Header: "NAMESPACE", Path: ".metadata.namespace"},
+		{Header: "DESIRED", Path: ".spec.replicas"},
+		{Header: "READY", Path: ".status.readyReplicas"},
+	},
+	"DaemonSet": {
+		{Header: "NAME", Path: ".metadata.name"},
+		{Header: "NAMESPACE", Path: ".metadata.namespace"},
+		{Header: "DESIRED", Path: ".status.desiredNumberScheduled"},
+		{Header: "READY", Path: ".status.numberReady"},
+	},
+	"Secret": {
+		{Header: "NAME", Path: ".metadata.name"},
+		{Header: "NAMESPACE", Path: ".metadata.namespace"},
+		{Header: "TYPE", Path: ".type"},
+	},
+}
+
+// objectColumns are the table columns for a kind without default columns
+var objectColumns = []Column{
+	{Header: "NAME", Path: ".metadata.name"},
+	{Header: "NAMESPACE", Path: ".metadata.namespace"},
+	{Header: "KIND", Path: ".kind"},
+}
+
+// NewFormatter creates a formatter from a kubectl style output spec
+// e.g. table, json, yaml, csv, jsonpath={.items[*].metadata.name}, go-template={{len .items}},
+// custom-columns=NAME:.metadata.name,IP:.status.podIP or csv=NAME:.metadata.name
+// spec: the output spec, empty is table
+// return: *Formatter, error
+func NewFormatter(spec string) (*Formatter, error) {
+	format, arg, _ := strings.Cut(spec, "=")
+	switch format {
+	case "", string(OutputTable):
+		return &Formatter{Format: OutputTable}, nil
+	case string(OutputJSON):
+		return &Formatter{Format: OutputJSON}, nil
+	case string(OutputYAML):
+		return &Formatter{Format: OutputYAML}, nil
+	case string(OutputJSONPath), string(OutputTemplate):
+		if arg == "" {
+			return nil, fmt.Errorf("%s needs an expression e.g. %s=<expression>", format, format)
+		}
+		return &Formatter{Format: OutputFormat(format), Template: arg}, nil
+	case string(OutputCSV), "custom-columns":
+		f := &Formatter{Format: OutputCSV}
+		if format == "custom-columns" {
+			f.Format = OutputTable
+			if arg == "" {
+				return nil, fmt.Errorf("custom-columns needs columns e.g. custom-columns=NAME:.metadata.name")
+			}
+		}
+		columns, err := ParseColumns(arg)
+		if err != nil {
+			return nil, err
+		}
+		f.Columns = columns
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown output format %s", spec)
+}
+
+// ParseColumns parses custom columns in the format HEADER:path,HEADER:path
+// spec: the columns e.g. NAME:.metadata.name,IP:.status.podIP
+// return: []Column, error
+func ParseColumns(spec string) ([]Column, error) {
+	var columns []Column
+	if spec == "" {
+		return columns, nil
+	}
+	for _, c := range strings.Split(spec, ",") {
+		header, path, found := strings.Cut(c, ":")
+		if !found || header == "" || path == "" {
+			return nil, fmt.Errorf("invalid column %s expected HEADER:path", c)
+		}
+		columns = append(columns, Column{Header: header, Path: path})
+	}
+	return columns, nil
+}
+
+// Write renders the data to the writer
+// data: a list e.g. *v1.PodList or *unstructured.UnstructuredList, a single object or a slice e.g. []ServiceDetails
+// w: the writer
+// return: error
+func (f *Formatter) Write(w io.Writer, data interface{}) error {
+	switch f.Format {
+	case OutputJSON:
+		out, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	case OutputYAML:
+		out, err := yaml.Marshal(data)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	}
+
+	generic, err := toGeneric(data)
+	if err != nil {
+		return err
+	}
+	switch f.Format {
+	case OutputJSONPath:
+		jp := jsonpath.New("output").AllowMissingKeys(true)
+		if err := jp.Parse(f.Template); err != nil {
+			return err
+		}
+		if err := jp.Execute(w, generic); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w)
+		return err
+	case OutputTemplate:
+		t, err := template.New("output").Parse(f.Template)
+		if err != nil {
+			return err
+		}
+		return t.Execute(w, generic)
+	case OutputTable, OutputCSV, "":
+		return f.writeRows(w, data, generic)
+	}
+	return fmt.Errorf("unknown output format %s", f.Format)
+}
+
+// writeRows renders the items as a table or csv
+// w: the writer
+// data: the original data used to pick the default columns
+// generic: the data converted to maps and slices
+// return: error
+func (f *Formatter) writeRows(w io.Writer, data interface{}, generic interface{}) error {
+	rows := toRows(generic)
+	columns := f.Columns
+	if len(columns) == 0 {
+		columns = columnsFor(data, rows)
+	}
+
+	//*********************
+	//Parse the column paths
+	//*********************
+	paths := make([]*jsonpath.JSONPath, len(columns))
+	for i, c := range columns {
+		path := c.Path
+		if !strings.HasPrefix(path, "{") {
+			if !strings.HasPrefix(path, ".") {
+				path = "." + path
+			}
+			path = "{" + path + "}"
+		}
+		paths[i] = jsonpath.New(c.Header).AllowMissingKeys(true)
+		if err := paths[i].Parse(path); err != nil {
+			return fmt.Errorf("column %s: %w", c.Header, err)
+		}
+	}
+
+	var table [][]string
+	if !f.NoHeaders {
+		var headers []string
+		for _, c := range columns {
+			headers = append(headers, c.Header)
+		}
+		table = append(table, headers)
+	}
+	for _, row := range rows {
+		var cells []string
+		for i := range columns {
+			if _, ok := row.(map[string]interface{}); !ok {
+				cells = append(cells, fmt.Sprint(row))
+				continue
+			}
+			var buf bytes.Buffer
+			if err := paths[i].Execute(&buf, row); err != nil {
+				return fmt.Errorf("column %s: %w", columns[i].Header, err)
+			}
+			cells = append(cells, buf.String())
+		}
+		table = append(table, cells)
+	}
+
+	if f.Format == OutputCSV {
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(table); err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	for _, cells := range table {
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// toGeneric converts the data to maps and slices using the json tags
+func toGeneric(data interface{}) (interface{}, error) {
+	out, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(out, &generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// toRows returns the items of a list, the elements of a slice or the single object
+func toRows(generic interface{}) []interface{} {
+	switch v := generic.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		if items, ok := v["items"]; ok {
+			list, _ := items.([]interface{})
+			return list
+		}
+		return []interface{}{v}
+	case nil:
+		return nil
+	}
+	return []interface{}{generic}
+}
+
+// columnsFor picks the default columns for the data
+// Kubernetes objects use the columns for their kind, other structs use their json fields
+func columnsFor(data interface{}, rows []interface{}) []Column {
+	kind := ""
+	if len(rows) > 0 {
+		if row, ok := rows[0].(map[string]interface{}); ok {
+			kind, _ = row["kind"].(string)
+		}
+	}
+
+	t := itemType(reflect.TypeOf(data))
+	if kind == "" && t != nil {
+		kind = t.Name()
+	}
+	if columns, ok := defaultColumns[kind]; ok {
+		return columns
+	}
+
+	if t != nil && t.Kind() == reflect.Struct {
+		if _, ok := t.FieldByName("ObjectMeta"); !ok {
+			var columns []Column
+			for i := 0; i < t.NumField(); i++ {
+				name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+				if name == "" || name == "-" || !t.Field(i).IsExported() {
+					continue
+				}
+				columns = append(columns, Column{Header: strings.ToUpper(name), Path: "." + name})
+			}
+			if len(columns) > 0 {
+				return columns
+			}
+		}
+	}
+	if len(rows) > 0 {
+		if _, ok := rows[0].(map[string]interface{}); !ok {
+			return []Column{{Header: "VALUE", Path: "@"}}
+		}
+	}
+	return objectColumns
+}
+
+// itemType returns the type of the items in a list or slice, or the type itself
+func itemType(t reflect.Type) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	} else if t.Kind() == reflect.Struct {
+		if items, ok := t.FieldByName("Items"); ok && items.Type.Kind() == reflect.Slice {
+			t = items.Type.Elem()
+		}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,124 @@
+package go_k8_helm
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []Column
+		wantErr bool
+	}{
+		{spec: "", want: nil},
+		{spec: "NAME:.metadata.name", want: []Column{{Header: "NAME", Path: ".metadata.name"}}},
+		{spec: "NAME:.metadata.name,PORTS:{.spec.ports[*].port}", want: []Column{{Header: "NAME", Path: ".metadata.name"}, {Header: "PORTS", Path: "{.spec.ports[*].port}"}}},
+		{spec: "IMAGE:.spec.containers[0].image", want: []Column{{Header: "IMAGE", Path: ".spec.containers[0].image"}}},
+		{spec: "NAME", wantErr: true},
+		{spec: ":.metadata.name", wantErr: true},
+		{spec: "NAME:", wantErr: true},
+		{spec: "NAME:.metadata.name,", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseColumns(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseColumns() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseColumns() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFormatter(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    *Formatter
+		wantErr bool
+	}{
+		{spec: "", want: &Formatter{Format: OutputTable}},
+		{spec: "table", want: &Formatter{Format: OutputTable}},
+		{spec: "json", want: &Formatter{Format: OutputJSON}},
+		{spec: "yaml", want: &Formatter{Format: OutputYAML}},
+		{spec: "csv", want: &Formatter{Format: OutputCSV}},
+		{spec: "csv=NAME:.metadata.name", want: &Formatter{Format: OutputCSV, Columns: []Column{{Header: "NAME", Path: ".metadata.name"}}}},
+		{spec: "custom-columns=NAME:.metadata.name", want: &Formatter{Format: OutputTable, Columns: []Column{{Header: "NAME", Path: ".metadata.name"}}}},
+		{spec: "jsonpath={.items[*].metadata.name}", want: &Formatter{Format: OutputJSONPath, Template: "{.items[*].metadata.name}"}},
+		{spec: "go-template={{len .items}}", want: &Formatter{Format: OutputTemplate, Template: "{{len .items}}"}},
+		{spec: "jsonpath={.a=1}", want: &Formatter{Format: OutputJSONPath, Template: "{.a=1}"}},
+		{spec: "jsonpath", wantErr: true},
+		{spec: "go-template=", wantErr: true},
+		{spec: "custom-columns", wantErr: true},
+		{spec: "custom-columns=NAME", wantErr: true},
+		{spec: "wide", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := NewFormatter(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewFormatter() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("NewFormatter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatterWrite(t *testing.T) {
+	pods := &v1.PodList{Items: []v1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-1"}, Status: v1.PodStatus{PodIP: "10.0.0.1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-2"}, Status: v1.PodStatus{PodIP: "10.0.0.2"}},
+	}}
+	tests := []struct {
+		spec string
+		data interface{}
+		want string
+	}{
+		{spec: "csv=NAME:.metadata.name,IP:.status.podIP", data: pods, want: "NAME,IP\nweb-1,10.0.0.1\nweb-2,10.0.0.2\n"},
+		{spec: "custom-columns=NAME:metadata.name", data: pods, want: "NAME\nweb-1\nweb-2\n"},
+		{spec: "jsonpath={.items[*].metadata.name}", data: pods, want: "web-1 web-2\n"},
+		{spec: "go-template={{len .items}}", data: pods, want: "2"},
+		{spec: "csv=NAME:.name,PORT:.port", data: []ServicePortDetails{{Name: "http", Port: 80}}, want: "NAME,PORT\nhttp,80\n"},
+		{spec: "csv=NAME:.metadata.name", data: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "single"}}, want: "NAME\nsingle\n"},
+		{spec: "csv=MISSING:.metadata.missing", data: pods, want: "MISSING\n\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			f, err := NewFormatter(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := f.Write(&buf, tt.data); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Fatalf("Write() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatterNoHeaders(t *testing.T) {
+	f, err := NewFormatter("custom-columns=NAME:.metadata.name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.NoHeaders = true
+	var buf bytes.Buffer
+	if err := f.Write(&buf, &v1.PodList{Items: []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web-1"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "NAME") || strings.TrimSpace(buf.String()) != "web-1" {
+		t.Fatalf("Write() = %q", buf.String())
+	}
+}
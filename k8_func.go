@@ -16,6 +16,7 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -162,30 +163,30 @@ func (m *K8) PodCopy(ns string, src string, dst string, container_name string) (
 	return out_str, nil
 }
 
-// dryRun returns the dry-run value for the given boolean value.
-func (m *K8) dryRun(dry_run bool) string {
-	if dry_run {
-		return metav1.DryRunAll
+// dryRunOptions returns the dry-run option for the create, update, patch and delete calls
+func (m *K8) dryRunOptions() []string {
+	if m.dry_run {
+		return []string{metav1.DryRunAll}
 	}
-	return ""
+	return nil
 }
 
-// ProcessK8File processes a k8 file with multiple definitions separated with ---
+// splitK8File splits a k8 file with multiple definitions separated with ---
 // file_data: file data
 // ns: namespace
-// apply: apply the file
-// return: error
-func (m *K8) ProcessK8File(file_data []byte, ns string, apply bool) error {
+// apply: if true and auto create namespace is set add a namespace definition when one is missing
+// return: the yaml parts
+func (m *K8) splitK8File(file_data []byte, ns string, apply bool) []string {
 	//**************************************
 	//Split the file and action on each part
 	//**************************************
 	yaml_data := strings.ReplaceAll(string(file_data), "\r\n", "\n")
-	parts := strings.Split(string(yaml_data), "---\n")
+	parts := splitYamlDocuments(yaml_data)
 
 	//**********************************
 	//See if there is a namespace create
 	//**********************************
-	if apply {
+	if apply && m.auto_create_ns {
 		found := false
 		for _, o := range parts {
 			if strings.Contains(o, "kind: Namespace") {
@@ -197,6 +198,45 @@ func (m *K8) ProcessK8File(file_data []byte, ns string, apply bool) error {
 			parts = str.InsertString(parts, 0, namespace)
 		}
 	}
+	return parts
+}
+
+// splitYamlDocuments splits yaml on the document separator lines
+// Only a line that is --- is a separator so a --- inside a block scalar is kept
+// yaml_data: the yaml with \n line endings
+// return: the documents
+func splitYamlDocuments(yaml_data string) []string {
+	var parts []string
+	var part strings.Builder
+	for _, line := range strings.SplitAfter(yaml_data, "\n") {
+		if strings.TrimRight(line, " \t\n") == "---" {
+			parts = append(parts, part.String())
+			part.Reset()
+			continue
+		}
+		part.WriteString(line)
+	}
+	return append(parts, part.String())
+}
+
+// ProcessK8File processes a k8 file with multiple definitions separated with ---
+// A Namespace definition is only added when SetAutoCreateNamespace is true
+// When applying, the policy rules are checked for every part before anything is applied
+// file_data: file data
+// ns: namespace
+// apply: apply the file
+// return: error
+func (m *K8) ProcessK8File(file_data []byte, ns string, apply bool) error {
+	parts := m.splitK8File(file_data, ns, apply)
+
+	//*****************************************
+	//Check the policies before applying any part
+	//*****************************************
+	if apply {
+		if err := m.checkPartsPolicies(parts, ns); err != nil {
+			return err
+		}
+	}
 
 	//**********************
 	//Loop through the parts
@@ -204,7 +244,7 @@ func (m *K8) ProcessK8File(file_data []byte, ns string, apply bool) error {
 	for _, o := range parts {
 		if apply {
 			if o != "" {
-				err := m.ApplyYaml(o, ns)
+				err := m.applyYaml(o, ns)
 				if err != nil {
 					return err
 				}
@@ -217,60 +257,136 @@ func (m *K8) ProcessK8File(file_data []byte, ns string, apply bool) error {
 	return nil
 }
 
-// DeleteYaml deletes a resource using a yaml manifest
-// ctx: context
-// cfg: k8 config
+// decodeYaml decodes a yaml manifest into an unstructured object
 // yaml: yaml manifest
-// ns: namespace
-// return: error
-func (m *K8) DeleteYaml(yaml string, ns string) error {
+// return: the object, the group version kind, error
+func decodeYaml(yaml string) (*unstructured.Unstructured, *schema.GroupVersionKind, error) {
+	obj := &unstructured.Unstructured{}
+	_, gvk, err := decUnstructured.Decode([]byte(yaml), nil, obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	return obj, gvk, nil
+}
 
-	// 1. Prepare a RESTMapper to find GVR
+// restMapping finds the rest mapping for a group version kind
+// gvk: the group version kind
+// return: the rest mapping, error
+func (m *K8) restMapping(gvk *schema.GroupVersionKind) (*meta.RESTMapping, error) {
 	dc, err := discovery.NewDiscoveryClientForConfig(m.config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
+	return mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
 
-	// 2. Prepare the dynamic client
+// resourceInterface finds the dynamic resource interface for an object
+// obj: the object, the namespace should already be set
+// mapping: the rest mapping of the object
+// return: the resource interface, error
+func (m *K8) resourceInterface(obj *unstructured.Unstructured, mapping *meta.RESTMapping) (dynamic.ResourceInterface, error) {
 	dyn, err := dynamic.NewForConfig(m.config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 3. Decode YAML manifest into unstructured.Unstructured
-	obj := &unstructured.Unstructured{}
-	_, gvk, err := decUnstructured.Decode([]byte(yaml), nil, obj)
-	if err != nil {
-		return err
+	// for cluster-wide resources
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return dyn.Resource(mapping.Resource), nil
 	}
 
-	if ns != "" {
+	// namespaced resources should specify the namespace
+	namespace := obj.GetNamespace()
+	if namespace == "" {
+		namespace = "default"
+	}
+	return dyn.Resource(mapping.Resource).Namespace(namespace), nil
+}
+
+// applyNamespacePolicy sets the namespace of an object using the namespace policy
+// Cluster-scoped objects never have a namespace set
+// obj: the object
+// mapping: the rest mapping of the object
+// ns: namespace
+// return: error if the policy rejects the object
+func (m *K8) applyNamespacePolicy(obj *unstructured.Unstructured, mapping *meta.RESTMapping, ns string) error {
+	return setObjectNamespace(obj, mapping.Scope.Name() == meta.RESTScopeNameNamespace, ns, m.namespace_policy)
+}
+
+// setObjectNamespace sets the namespace of an object using a namespace policy
+// obj: the object
+// namespaced: false for a cluster-scoped object, its namespace is removed
+// ns: namespace
+// policy: the namespace policy
+// return: error if the policy rejects the object
+func setObjectNamespace(obj *unstructured.Unstructured, namespaced bool, ns string, policy NamespaceMode) error {
+	if !namespaced {
+		obj.SetNamespace("")
+		return nil
+	}
+	if ns == "" {
+		return nil
+	}
+
+	switch policy {
+	case NamespaceFillEmpty:
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(ns)
+		}
+	case NamespaceRejectMismatch:
+		if obj.GetNamespace() != "" && obj.GetNamespace() != ns {
+			return fmt.Errorf("kind(%s) name(%s) declares namespace %s but %s was requested", obj.GetKind(), obj.GetName(), obj.GetNamespace(), ns)
+		}
 		obj.SetNamespace(ns)
+	default:
+		obj.SetNamespace(ns)
+	}
+	return nil
+}
+
+// prepareYaml decodes a yaml manifest, sets the namespace
+// and finds the dynamic resource interface for it
+// The mutation is not applied, it is only used on the apply path
+// yaml: yaml manifest
+// ns: namespace
+// return: the object, the resource interface, error
+func (m *K8) prepareYaml(yaml string, ns string) (*unstructured.Unstructured, dynamic.ResourceInterface, error) {
+	obj, gvk, err := decodeYaml(yaml)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// 4. Find GVR
-	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	mapping, err := m.restMapping(gvk)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	//Get the namespace
-	namespace := ""
-	if obj.GetNamespace() != "" {
-		namespace = obj.GetNamespace()
-	} else {
-		namespace = "default"
+	err = m.applyNamespacePolicy(obj, mapping, ns)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// 5. Obtain REST interface for the GVR
-	var dr dynamic.ResourceInterface
-	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
-		// namespaced resources should specify the namespace
-		dr = dyn.Resource(mapping.Resource).Namespace(namespace)
-	} else {
-		// for cluster-wide resources
-		dr = dyn.Resource(mapping.Resource)
+	dr, err := m.resourceInterface(obj, mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+	return obj, dr, nil
+}
+
+// DeleteYaml deletes a resource using a yaml manifest
+// Foreground propagation is used unless the options say otherwise
+// ctx: context
+// cfg: k8 config
+// yaml: yaml manifest
+// ns: namespace
+// opts: delete options e.g. OptionDeleteWait
+// return: error
+func (m *K8) DeleteYaml(yaml string, ns string, opts ...DeleteOption) error {
+
+	obj, dr, err := m.prepareYaml(yaml, ns)
+	if err != nil {
+		return err
 	}
 
 	log.Printf("Info: Deleting Kind(%s) Namespace(%s) Name(%s)\n", obj.GetKind(), obj.GetNamespace(), obj.GetName())
@@ -278,11 +394,8 @@ func (m *K8) DeleteYaml(yaml string, ns string) error {
 	//********************
 	//Lets delete the item
 	//********************
-	deletePolicy := metav1.DeletePropagationForeground
-	deleteOptions := metav1.DeleteOptions{
-		PropagationPolicy: &deletePolicy,
-	}
-	err = dr.Delete(m.ctx, obj.GetName(), deleteOptions)
+	defaults := []DeleteOption{OptionDeletePropagation(metav1.DeletePropagationForeground)}
+	err = dynamicDeleteAndWait(dr, obj.GetName(), defaults, opts)
 	if err != nil {
 		return fmt.Errorf("info: Failed to delete Kind(%s) Namespace(%s) Name(%s) Error(%s)", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err.Error())
 	}
@@ -291,63 +404,39 @@ func (m *K8) DeleteYaml(yaml string, ns string) error {
 }
 
 // ApplyYaml applies a resource using a yaml manifest
+// The policy rules are checked before the resource is applied
+// SOPS documents and encrypted secret values are decrypted in memory
 // ctx: context
 // cfg: k8 config
 // yaml: yaml manifest
 // ns: namespace
 // return: error
 func (m *K8) ApplyYaml(yaml string, ns string) error {
-
-	// 1. Prepare a RESTMapper to find GVR
-	dc, err := discovery.NewDiscoveryClientForConfig(m.config)
-	if err != nil {
+	if err := m.checkPartsPolicies([]string{yaml}, ns); err != nil {
 		return err
 	}
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
+	return m.applyYaml(yaml, ns)
+}
 
-	// 2. Prepare the dynamic client
-	dyn, err := dynamic.NewForConfig(m.config)
-	if err != nil {
-		return err
-	}
+// applyYaml applies a resource using a yaml manifest without checking the policies
+// yaml: yaml manifest
+// ns: namespace
+// return: error
+func (m *K8) applyYaml(yaml string, ns string) error {
 
-	// 3. Decode YAML manifest into unstructured.Unstructured
-	obj := &unstructured.Unstructured{}
-	_, gvk, err := decUnstructured.Decode([]byte(yaml), nil, obj)
+	obj, dr, err := m.prepareYaml(yaml, ns)
 	if err != nil {
 		return err
 	}
+	m.mutateObject(obj)
 
-	if ns != "" {
-		obj.SetNamespace(ns)
-		//log.Print("Info: Setting Namespace " + obj.GetNamespace() + "")
-	}
-
-	// 4. Find GVR
-	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	// Decrypt encrypted secrets in memory
+	err = m.decryptObject(obj, yaml)
 	if err != nil {
 		return err
 	}
 
-	//Get the namespace
-	namespace := ""
-	if obj.GetNamespace() != "" {
-		namespace = obj.GetNamespace()
-	} else {
-		namespace = "default"
-	}
-
-	// 5. Obtain REST interface for the GVR
-	var dr dynamic.ResourceInterface
-	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
-		// namespaced resources should specify the namespace
-		dr = dyn.Resource(mapping.Resource).Namespace(namespace)
-	} else {
-		// for cluster-wide resources
-		dr = dyn.Resource(mapping.Resource)
-	}
-
-	// 6. Marshal object into JSON
+	// Marshal object into JSON
 	data, err := json.Marshal(obj)
 	if err != nil {
 		return err
@@ -363,7 +452,7 @@ func (m *K8) ApplyYaml(yaml string, ns string) error {
 
 	_, err = dr.Patch(m.ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
 		FieldManager: "package-manager",
-		DryRun:       []string{m.dryRun(m.dry_run)},
+		DryRun:       m.dryRunOptions(),
 	})
 	if err == nil {
 		fmt.Printf("Info: Created Kind(%s) Namespace(%s) Name(%s)\n", obj.GetKind(), obj.GetNamespace(), obj.GetName())
@@ -412,8 +501,9 @@ func (m *K8) ApplyYaml(yaml string, ns string) error {
 
 // GetSecrets gets secrets from a k8 cluster
 // ns: namespace
+// opts: list options e.g. OptionListLabelSelector("app=web")
 // return: v1.SecretList, error
-func (m *K8) GetSecrets(ns string) (*v1.SecretList, error) {
+func (m *K8) GetSecrets(ns string, opts ...ListOption) (*v1.SecretList, error) {
 
 	//**********************
 	// creates the clientset
@@ -423,9 +513,19 @@ func (m *K8) GetSecrets(ns string) (*v1.SecretList, error) {
 		return nil, err
 	}
 
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// answer from the local cache when it is running
+	if cached, ok, err := m.cachedSecrets(ns, list_options); ok {
+		return cached, err
+	}
+
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	pods, err := client_set.CoreV1().Secrets(ns).List(context.TODO(), metav1.ListOptions{})
+	pods, err := client_set.CoreV1().Secrets(ns).List(context.TODO(), list_options)
 	if err != nil {
 		return nil, err
 	}
@@ -435,8 +535,9 @@ func (m *K8) GetSecrets(ns string) (*v1.SecretList, error) {
 // DeleteSecrets deletes secrets from a k8 cluster
 // ns: namespace
 // name: name of secret
+// opts: delete options e.g. OptionDeleteWait
 // return: error
-func (m *K8) DeleteSecrets(ns string, name string) error {
+func (m *K8) DeleteSecrets(ns string, name string, opts ...DeleteOption) error {
 
 	//**********************
 	// creates the clientset
@@ -448,17 +549,15 @@ func (m *K8) DeleteSecrets(ns string, name string) error {
 
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	err = client_set.CoreV1().Secrets(ns).Delete(context.TODO(), name, metav1.DeleteOptions{})
-	if err != nil {
-		return err
-	}
-	return nil
+	client := client_set.CoreV1().Secrets(ns)
+	return deleteAndWait(name, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
 }
 
 // GetPods gets pods from a k8 cluster
 // ns: namespace
+// opts: list options e.g. OptionListLabelSelector("app=web")
 // return: v1.PodList, error
-func (m *K8) GetPods(ns string) (*v1.PodList, error) {
+func (m *K8) GetPods(ns string, opts ...ListOption) (*v1.PodList, error) {
 
 	//**********************
 	// creates the clientset
@@ -468,9 +567,19 @@ func (m *K8) GetPods(ns string) (*v1.PodList, error) {
 		return nil, err
 	}
 
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// answer from the local cache when it is running
+	if cached, ok, err := m.cachedPods(ns, list_options); ok {
+		return cached, err
+	}
+
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	pods, err := client_set.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{})
+	pods, err := client_set.CoreV1().Pods(ns).List(context.TODO(), list_options)
 	if err != nil {
 		return nil, err
 	}
@@ -480,8 +589,9 @@ func (m *K8) GetPods(ns string) (*v1.PodList, error) {
 // DeletePod deletes a pod from a k8 cluster
 // ns: namespace
 // name: pod name
+// opts: delete options e.g. OptionDeleteWait
 // return: error
-func (m *K8) DeletePod(ns string, name string) error {
+func (m *K8) DeletePod(ns string, name string, opts ...DeleteOption) error {
 
 	//**********************
 	// creates the clientset
@@ -493,18 +603,16 @@ func (m *K8) DeletePod(ns string, name string) error {
 
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	err = client_set.CoreV1().Pods(ns).Delete(context.TODO(), name, metav1.DeleteOptions{})
-	if err != nil {
-		return err
-	}
-	return nil
+	client := client_set.CoreV1().Pods(ns)
+	return deleteAndWait(name, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
 }
 
 // GetServices gets services from a k8 cluster
 // ns: namespace
+// opts: list options e.g. OptionListLabelSelector("app=web")
 //
 //	return: v1.ServiceList, error
-func (m *K8) GetServices(ns string) (*v1.ServiceList, error) {
+func (m *K8) GetServices(ns string, opts ...ListOption) (*v1.ServiceList, error) {
 
 	//**********************
 	// creates the clientset
@@ -514,9 +622,19 @@ func (m *K8) GetServices(ns string) (*v1.ServiceList, error) {
 		return nil, err
 	}
 
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// answer from the local cache when it is running
+	if cached, ok, err := m.cachedServices(ns, list_options); ok {
+		return cached, err
+	}
+
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	pods, err := clientset.CoreV1().Services(ns).List(context.TODO(), metav1.ListOptions{})
+	pods, err := clientset.CoreV1().Services(ns).List(context.TODO(), list_options)
 	if err != nil {
 		return nil, err
 	}
@@ -526,8 +644,9 @@ func (m *K8) GetServices(ns string) (*v1.ServiceList, error) {
 // DeleteService deletes a service from a k8 cluster
 // ns: namespace
 // name: name of the service
+// opts: delete options e.g. OptionDeleteWait
 // return: error
-func (m *K8) DeleteService(ns string, name string) error {
+func (m *K8) DeleteService(ns string, name string, opts ...DeleteOption) error {
 
 	//**********************
 	// creates the clientset
@@ -539,17 +658,15 @@ func (m *K8) DeleteService(ns string, name string) error {
 
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	err = client_set.CoreV1().Services(ns).Delete(context.TODO(), name, metav1.DeleteOptions{})
-	if err != nil {
-		return err
-	}
-	return nil
+	client := client_set.CoreV1().Services(ns)
+	return deleteAndWait(name, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
 }
 
 // GetDeployments gets deployments from a k8 cluster
 // ns: namespace
+// opts: list options e.g. OptionListLabelSelector("app=web")
 // return: appsv1.DeploymentList, error
-func (m *K8) GetDeployments(ns string) (*appsv1.DeploymentList, error) {
+func (m *K8) GetDeployments(ns string, opts ...ListOption) (*appsv1.DeploymentList, error) {
 
 	//**********************
 	// creates the clientset
@@ -559,16 +676,26 @@ func (m *K8) GetDeployments(ns string) (*appsv1.DeploymentList, error) {
 		return nil, err
 	}
 
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// answer from the local cache when it is running
+	if cached, ok, err := m.cachedDeployments(ns, list_options); ok {
+		return cached, err
+	}
+
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	pods, err := clientset.AppsV1().Deployments(ns).List(context.TODO(), metav1.ListOptions{})
+	pods, err := clientset.AppsV1().Deployments(ns).List(context.TODO(), list_options)
 	if err != nil {
 		return nil, err
 	}
 	return pods, nil
 }
 
-func (m *K8) DeleteDeployment(ns string, name string) error {
+func (m *K8) DeleteDeployment(ns string, name string, opts ...DeleteOption) error {
 
 	//**********************
 	// creates the clientset
@@ -580,17 +707,15 @@ func (m *K8) DeleteDeployment(ns string, name string) error {
 
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	err = client_set.AppsV1().Deployments(ns).Delete(context.TODO(), name, metav1.DeleteOptions{})
-	if err != nil {
-		return err
-	}
-	return nil
+	client := client_set.AppsV1().Deployments(ns)
+	return deleteAndWait(name, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
 }
 
 // GetStatefulSets gets statefulsets from a k8 cluster
 // ns: namespace
+// opts: list options e.g. OptionListLabelSelector("app=web")
 // return: appsv1.StatefulSetList, error
-func (m *K8) GetStatefulSets(ns string) (*appsv1.StatefulSetList, error) {
+func (m *K8) GetStatefulSets(ns string, opts ...ListOption) (*appsv1.StatefulSetList, error) {
 
 	//**********************
 	// creates the clientset
@@ -600,9 +725,19 @@ func (m *K8) GetStatefulSets(ns string) (*appsv1.StatefulSetList, error) {
 		return nil, err
 	}
 
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// answer from the local cache when it is running
+	if cached, ok, err := m.cachedStatefulSets(ns, list_options); ok {
+		return cached, err
+	}
+
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	pods, err := clientset.AppsV1().StatefulSets(ns).List(context.TODO(), metav1.ListOptions{})
+	pods, err := clientset.AppsV1().StatefulSets(ns).List(context.TODO(), list_options)
 	if err != nil {
 		return nil, err
 	}
@@ -612,8 +747,9 @@ func (m *K8) GetStatefulSets(ns string) (*appsv1.StatefulSetList, error) {
 // DeleteStatefulSets deletes a statefulset from a k8 cluster
 // ns: namespace
 // name: name of the statefulset
+// opts: delete options e.g. OptionDeleteWait
 // return: error
-func (m *K8) DeleteStatefulSets(ns string, name string) error {
+func (m *K8) DeleteStatefulSets(ns string, name string, opts ...DeleteOption) error {
 
 	//**********************
 	// creates the clientset
@@ -625,17 +761,15 @@ func (m *K8) DeleteStatefulSets(ns string, name string) error {
 
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	err = client_set.AppsV1().StatefulSets(ns).Delete(context.TODO(), name, metav1.DeleteOptions{})
-	if err != nil {
-		return err
-	}
-	return nil
+	client := client_set.AppsV1().StatefulSets(ns)
+	return deleteAndWait(name, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
 }
 
 // GetDemonSet gets demonsets from a k8 cluster
 // ns: namespace
+// opts: list options e.g. OptionListLabelSelector("app=web")
 // return: appsv1.DaemonSetList, error
-func (m *K8) GetDemonSet(ns string) (*appsv1.DaemonSetList, error) {
+func (m *K8) GetDemonSet(ns string, opts ...ListOption) (*appsv1.DaemonSetList, error) {
 
 	//**********************
 	// creates the clientset
@@ -645,9 +779,19 @@ func (m *K8) GetDemonSet(ns string) (*appsv1.DaemonSetList, error) {
 		return nil, err
 	}
 
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// answer from the local cache when it is running
+	if cached, ok, err := m.cachedDemonSets(ns, list_options); ok {
+		return cached, err
+	}
+
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	pods, err := clientset.AppsV1().DaemonSets(ns).List(context.TODO(), metav1.ListOptions{})
+	pods, err := clientset.AppsV1().DaemonSets(ns).List(context.TODO(), list_options)
 	if err != nil {
 		return nil, err
 	}
@@ -656,8 +800,9 @@ func (m *K8) GetDemonSet(ns string) (*appsv1.DaemonSetList, error) {
 
 // GetDemonSet gets demonsets from a k8 cluster
 // ns: namespace
+// opts: delete options e.g. OptionDeleteWait
 // return: appsv1.DaemonSetList, error
-func (m *K8) DeleteDemonSet(ns string, name string) error {
+func (m *K8) DeleteDemonSet(ns string, name string, opts ...DeleteOption) error {
 
 	//**********************
 	// creates the clientset
@@ -669,18 +814,19 @@ func (m *K8) DeleteDemonSet(ns string, name string) error {
 
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	err = clientset.AppsV1().DaemonSets(ns).Delete(context.TODO(), name, metav1.DeleteOptions{})
-	if err != nil {
-		return err
-	}
-	return nil
+	client := clientset.AppsV1().DaemonSets(ns)
+	return deleteAndWait(name, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
 }
 
 // GetServiceIP gets service ip from a k8 cluster
+// There is one result for each load balancer address or cluster ip of a service
+// The results have all the ports, the node addresses for node ports, the external name
+// and the number of ready endpoints from the EndpointSlices
 // ns: namespace
 // regex_service_name: regex to match service name
-// return: v1.ServiceList, error
-func (m *K8) GetServiceIP(ns string, regex_service_name string) ([]ServiceDetails, error) {
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: []ServiceDetails, error
+func (m *K8) GetServiceIP(ns string, regex_service_name string, opts ...ListOption) ([]ServiceDetails, error) {
 
 	//**********************
 	// creates the clientset
@@ -690,55 +836,204 @@ func (m *K8) GetServiceIP(ns string, regex_service_name string) ([]ServiceDetail
 		return nil, err
 	}
 
-	// get pods in all the namespaces by omitting namespace
-	// Or specify namespace to get pods in particular namespace
-	services, err := clientset.CoreV1().Services(ns).List(context.TODO(), metav1.ListOptions{})
+	services, err := m.GetServices(ns, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	var node_addresses []string
+	nodes_listed := false
+	endpoints := map[string][2]int{}
+	endpoints_listed := false
+
 	var ports []ServiceDetails
 	for _, o := range services.Items {
-		if len(o.Status.LoadBalancer.Ingress) > 0 {
-			for _, i := range o.Status.LoadBalancer.Ingress {
-				res, _ := regexp.MatchString(regex_service_name, o.Name)
-				if res {
-					if len(o.Spec.Ports) > 0 {
+		res, _ := regexp.MatchString(regex_service_name, o.Name)
+		if !res {
+			continue
+		}
 
-						ports = append(ports, ServiceDetails{ServiceType: "LoadBalancer", ServiceName: o.Name, IP: i.IP, Port: o.Spec.Ports[0].Port})
-						//log.Printf("Info: %s; %s:%v", o.Name, i.IP, o.Spec.Ports[0].Port)
-					} else {
-						ports = append(ports, ServiceDetails{ServiceType: "LoadBalancer", ServiceName: o.Name, IP: i.IP})
-						//log.Printf("Info: %s; %s", o.Name, i.IP)
-					}
+		details := ServiceDetails{
+			ServiceType:  string(o.Spec.Type),
+			ServiceName:  o.Name,
+			Namespace:    o.Namespace,
+			ExternalName: o.Spec.ExternalName,
+			ClusterIPs:   o.Spec.ClusterIPs,
+		}
+		if details.ServiceType == "" {
+			details.ServiceType = string(v1.ServiceTypeClusterIP)
+		}
+		has_node_port := false
+		for _, p := range o.Spec.Ports {
+			protocol := string(p.Protocol)
+			if protocol == "" {
+				protocol = string(v1.ProtocolTCP)
+			}
+			details.Ports = append(details.Ports, ServicePortDetails{
+				Name:       p.Name,
+				Protocol:   protocol,
+				Port:       p.Port,
+				TargetPort: p.TargetPort.String(),
+				NodePort:   p.NodePort,
+			})
+			has_node_port = has_node_port || p.NodePort != 0
+		}
+		if len(o.Spec.Ports) > 0 {
+			details.Port = o.Spec.Ports[0].Port
+		}
 
+		//*******************************
+		//Node addresses for the node ports
+		//*******************************
+		if has_node_port {
+			if !nodes_listed {
+				nodes_listed = true
+				node_addresses, err = nodeAddresses(clientset)
+				if err != nil {
+					log.Printf("Info: Unable to list nodes Error(%s)\n", err.Error())
 				}
 			}
-		} else if len(o.Spec.ClusterIPs) > 0 {
-			for _, i := range o.Spec.ClusterIPs {
-				res, _ := regexp.MatchString(regex_service_name, o.Name)
-				if res {
-					if len(o.Spec.Ports) > 0 {
-						ports = append(ports, ServiceDetails{ServiceType: "ClusterIP", ServiceName: o.Name, IP: i, Port: o.Spec.Ports[0].Port})
-						//log.Printf("Info: %s; %s", o.Name, i)
-					} else {
-						ports = append(ports, ServiceDetails{ServiceType: "ClusterIP", ServiceName: o.Name, IP: i})
-						//log.Printf("Info: %s; %s", o.Name, i)
-					}
+			details.NodeAddresses = node_addresses
+		}
 
+		//*****************************************
+		//Count the endpoints from the EndpointSlices
+		//*****************************************
+		if o.Spec.Type != v1.ServiceTypeExternalName {
+			if !endpoints_listed {
+				endpoints_listed = true
+				endpoints, err = serviceEndpointCounts(clientset, ns)
+				if err != nil {
+					log.Printf("Info: Unable to list endpoint slices Error(%s)\n", err.Error())
 				}
 			}
+			counts := endpoints[o.Namespace+"/"+o.Name]
+			details.ReadyEndpoints, details.Endpoints = counts[0], counts[1]
+		}
+
+		switch {
+		case len(o.Status.LoadBalancer.Ingress) > 0:
+			for _, i := range o.Status.LoadBalancer.Ingress {
+				d := details
+				d.IP = i.IP
+				d.Hostname = i.Hostname
+				ports = append(ports, d)
+			}
+		case len(o.Spec.ClusterIPs) > 0:
+			for _, i := range o.Spec.ClusterIPs {
+				d := details
+				d.IP = i
+				ports = append(ports, d)
+			}
+		default:
+			ports = append(ports, details)
 		}
 	}
 
 	return ports, nil
 }
 
+// nodeAddresses gets an address of each node preferring the external ip
+// clientset: the clientset
+// return: the node addresses, error
+func nodeAddresses(clientset *kubernetes.Clientset) ([]string, error) {
+	nodes, err := clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, n := range nodes.Items {
+		found := map[v1.NodeAddressType]string{}
+		for _, a := range n.Status.Addresses {
+			if _, ok := found[a.Type]; !ok {
+				found[a.Type] = a.Address
+			}
+		}
+		for _, t := range []v1.NodeAddressType{v1.NodeExternalIP, v1.NodeInternalIP, v1.NodeExternalDNS, v1.NodeHostName} {
+			if a, ok := found[t]; ok {
+				addresses = append(addresses, a)
+				break
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// serviceEndpointCounts counts the ready and total endpoints of each service from the EndpointSlices
+// clientset: the clientset
+// ns: namespace, empty for all namespaces
+// return: namespace/name to ready and total counts, error
+func serviceEndpointCounts(clientset *kubernetes.Clientset, ns string) (map[string][2]int, error) {
+	slices, err := clientset.DiscoveryV1().EndpointSlices(ns).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return map[string][2]int{}, err
+	}
+	return countEndpoints(slices.Items), nil
+}
+
+// countEndpoints counts the ready and total endpoints of each service
+// A dual-stack service has an IPv4 and an IPv6 slice for the same pods,
+// so endpoints are counted once per target and the others once per address type
+// slices: the EndpointSlices
+// return: namespace/name to ready and total counts
+func countEndpoints(slices []discoveryv1.EndpointSlice) map[string][2]int {
+	targets := map[string]map[string]bool{}
+	untargeted := map[string]map[discoveryv1.AddressType][2]int{}
+	for _, slice := range slices {
+		service := slice.Labels[discoveryv1.LabelServiceName]
+		if service == "" {
+			continue
+		}
+		key := slice.Namespace + "/" + service
+		if targets[key] == nil {
+			targets[key] = map[string]bool{}
+			untargeted[key] = map[discoveryv1.AddressType][2]int{}
+		}
+		for _, e := range slice.Endpoints {
+			ready := e.Conditions.Ready == nil || *e.Conditions.Ready
+			if e.TargetRef != nil {
+				target := e.TargetRef.Kind + "/" + e.TargetRef.Namespace + "/" + e.TargetRef.Name
+				targets[key][target] = targets[key][target] || ready
+				continue
+			}
+			c := untargeted[key][slice.AddressType]
+			c[1]++
+			if ready {
+				c[0]++
+			}
+			untargeted[key][slice.AddressType] = c
+		}
+	}
+
+	counts := map[string][2]int{}
+	for key, seen := range targets {
+		var c [2]int
+		for _, ready := range seen {
+			c[1]++
+			if ready {
+				c[0]++
+			}
+		}
+		// the same endpoints are listed for each address type
+		var most [2]int
+		for _, u := range untargeted[key] {
+			if u[1] > most[1] {
+				most = u
+			}
+		}
+		c[0] += most[0]
+		c[1] += most[1]
+		counts[key] = c
+	}
+	return counts
+}
+
 // DeleteNS deletes a namespace in a k8 cluster
 // ns: namespace
+// opts: delete options e.g. OptionDeleteWait
 // return: error
 // Does not delete default namespace
-func (m *K8) DeleteNS(ns string) error {
+func (m *K8) DeleteNS(ns string, opts ...DeleteOption) error {
 
 	if strings.ToLower(ns) == "default" {
 		return errors.New("cannot delete default name space")
@@ -754,11 +1049,8 @@ func (m *K8) DeleteNS(ns string) error {
 
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	err = client_set.CoreV1().Namespaces().Delete(context.TODO(), ns, metav1.DeleteOptions{})
-	if err != nil {
-		return err
-	}
-	return nil
+	client := client_set.CoreV1().Namespaces()
+	return deleteAndWait(ns, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
 }
 
 // CreateNS creates a namespace in a k8 cluster
@@ -790,7 +1082,7 @@ func (m *K8) CreateNS(ns string) error {
 
 // CheckStatusOf checks the status of a list of checks
 // ns: namespace
-// checks: list of checks to perform
+// checks: list of checks to perform in the format type:name_regex[:label_selector[:field_selector]]
 // return: bool, []string, error
 // bool: true if all checks passed
 // []string: list of the results of the checks
@@ -803,6 +1095,8 @@ func (m *K8) CreateNS(ns string) error {
 			"stateful:nginx3(.*)",
 			"demon:nginx4(.*)",
 			"service:nginx(.*)",
+			"deployment:(.*):app=web,tier!=cache",
+			"service::app=web",
 		}
 */
 func (m *K8) CheckStatusOf(ns string, checks []interface{}, not_running bool) (bool, []string, error) {
@@ -812,26 +1106,55 @@ func (m *K8) CheckStatusOf(ns string, checks []interface{}, not_running bool) (b
 	all_completed := true
 	//all_not_running := true
 	//type:name
-	deployments, err := m.GetDeployments(ns)
+	all_deployments, err := m.GetDeployments(ns)
 	if err != nil {
 		return false, nil, err
 	}
-	stateful, err := m.GetStatefulSets(ns)
+	all_stateful, err := m.GetStatefulSets(ns)
 	if err != nil {
 		return false, nil, err
 	}
-	demonset, err := m.GetDemonSet(ns)
+	all_demonset, err := m.GetDemonSet(ns)
 	if err != nil {
 		return false, nil, err
 	}
-	services, err := m.GetServices(ns)
+	all_services, err := m.GetServices(ns)
 	if err != nil {
 		return false, nil, err
 	}
 	//Loop through the checks
 	for _, check := range checks {
-		//Split the check into type and name
-		checks := strings.Split(check.(string), ":")
+		//Split the check into type, name and the optional selectors
+		checks := strings.SplitN(check.(string), ":", 4)
+		if len(checks) < 2 {
+			checks = append(checks, "")
+		}
+		var list_opts []ListOption
+		if len(checks) > 2 && checks[2] != "" {
+			list_opts = append(list_opts, OptionListLabelSelector(checks[2]))
+		}
+		if len(checks) > 3 && checks[3] != "" {
+			list_opts = append(list_opts, OptionListFieldSelector(checks[3]))
+		}
+
+		//Only list again when the check has selectors
+		deployments, stateful, demonset, services := all_deployments, all_stateful, all_demonset, all_services
+		if len(list_opts) > 0 {
+			switch checks[0] {
+			case "deployment", "replica":
+				deployments, err = m.GetDeployments(ns, list_opts...)
+			case "stateful":
+				stateful, err = m.GetStatefulSets(ns, list_opts...)
+			case "service":
+				services, err = m.GetServices(ns, list_opts...)
+			case "demonset":
+				demonset, err = m.GetDemonSet(ns, list_opts...)
+			}
+			if err != nil {
+				return false, nil, err
+			}
+		}
+
 		switch checks[0] {
 		case "deployment", "replica":
 			for _, o := range deployments.Items {
@@ -917,8 +1240,9 @@ func (m *K8) CheckStatusOf(ns string, checks []interface{}, not_running bool) (b
 // DeletePVC deletes a PVC
 // - ns is the namespace
 // - name is the name of the PVC
+// - opts are the delete options e.g. OptionDeleteWait
 // - returns an error if there is one
-func (m *K8) DeletePVC(ns string, name string) error {
+func (m *K8) DeletePVC(ns string, name string, opts ...DeleteOption) error {
 
 	//**********************
 	// creates the clientset
@@ -930,18 +1254,16 @@ func (m *K8) DeletePVC(ns string, name string) error {
 
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	err = client_set.CoreV1().PersistentVolumeClaims(ns).Delete(context.TODO(), name, metav1.DeleteOptions{})
-	if err != nil {
-		return err
-	}
-	return nil
+	client := client_set.CoreV1().PersistentVolumeClaims(ns)
+	return deleteAndWait(name, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
 }
 
 // DeletePV deletes a PV
 // - ns is the namespace
 // - name is the name of the PV
+// - opts are the delete options e.g. OptionDeleteWait
 // - returns an error if there is one
-func (m *K8) DeletePV(ns string, name string) error {
+func (m *K8) DeletePV(ns string, name string, opts ...DeleteOption) error {
 
 	//**********************
 	// creates the clientset
@@ -953,9 +1275,6 @@ func (m *K8) DeletePV(ns string, name string) error {
 
 	// get pods in all the namespaces by omitting namespace
 	// Or specify namespace to get pods in particular namespace
-	err = client_set.CoreV1().PersistentVolumes().Delete(context.TODO(), name, metav1.DeleteOptions{})
-	if err != nil {
-		return err
-	}
-	return nil
+	client := client_set.CoreV1().PersistentVolumes()
+	return deleteAndWait(name, client.Delete, deleteAndWaitGetter(client.Get), nil, opts)
 }
@@ -0,0 +1,204 @@
+package go_k8_helm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// testSlice builds an EndpointSlice of a service
+func testSlice(service string, address_type discoveryv1.AddressType, endpoints ...discoveryv1.Endpoint) discoveryv1.EndpointSlice {
+	return discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+		AddressType: address_type,
+		Endpoints:   endpoints,
+	}
+}
+
+// testEndpoint builds an endpoint for a pod, an empty pod has no target
+func testEndpoint(pod string, ready bool) discoveryv1.Endpoint {
+	e := discoveryv1.Endpoint{Conditions: discoveryv1.EndpointConditions{Ready: &ready}}
+	if pod != "" {
+		e.TargetRef = &v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: pod}
+	}
+	return e
+}
+
+func TestCountEndpoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		slices []discoveryv1.EndpointSlice
+		want   map[string][2]int
+	}{
+		{
+			name: "single stack",
+			slices: []discoveryv1.EndpointSlice{
+				testSlice("web", discoveryv1.AddressTypeIPv4, testEndpoint("a", true), testEndpoint("b", false)),
+			},
+			want: map[string][2]int{"default/web": {1, 2}},
+		},
+		{
+			name: "dual stack",
+			slices: []discoveryv1.EndpointSlice{
+				testSlice("web", discoveryv1.AddressTypeIPv4, testEndpoint("a", true), testEndpoint("b", false)),
+				testSlice("web", discoveryv1.AddressTypeIPv6, testEndpoint("a", true), testEndpoint("b", false)),
+			},
+			want: map[string][2]int{"default/web": {1, 2}},
+		},
+		{
+			name: "split over slices",
+			slices: []discoveryv1.EndpointSlice{
+				testSlice("web", discoveryv1.AddressTypeIPv4, testEndpoint("a", true)),
+				testSlice("web", discoveryv1.AddressTypeIPv4, testEndpoint("b", true)),
+			},
+			want: map[string][2]int{"default/web": {2, 2}},
+		},
+		{
+			name: "dual stack without targets",
+			slices: []discoveryv1.EndpointSlice{
+				testSlice("ext", discoveryv1.AddressTypeIPv4, testEndpoint("", true), testEndpoint("", true)),
+				testSlice("ext", discoveryv1.AddressTypeIPv6, testEndpoint("", true), testEndpoint("", true)),
+			},
+			want: map[string][2]int{"default/ext": {2, 2}},
+		},
+		{
+			name: "unlabelled slice",
+			slices: []discoveryv1.EndpointSlice{
+				testSlice("", discoveryv1.AddressTypeIPv4, testEndpoint("a", true)),
+			},
+			want: map[string][2]int{},
+		},
+		{
+			name: "ready unknown counts as ready",
+			slices: []discoveryv1.EndpointSlice{
+				testSlice("web", discoveryv1.AddressTypeIPv4, discoveryv1.Endpoint{TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "a"}}),
+			},
+			want: map[string][2]int{"default/web": {1, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := countEndpoints(tt.slices)
+			if len(got) != len(tt.want) {
+				t.Fatalf("countEndpoints() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Fatalf("countEndpoints()[%s] = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyNamespacePolicy(t *testing.T) {
+	namespaced := &meta.RESTMapping{Scope: meta.RESTScopeNamespace}
+	cluster := &meta.RESTMapping{Scope: meta.RESTScopeRoot}
+	tests := []struct {
+		name     string
+		policy   NamespaceMode
+		mapping  *meta.RESTMapping
+		declared string
+		ns       string
+		want     string
+		wantErr  bool
+	}{
+		{name: "override empty", policy: NamespaceOverride, mapping: namespaced, ns: "app", want: "app"},
+		{name: "override declared", policy: NamespaceOverride, mapping: namespaced, declared: "other", ns: "app", want: "app"},
+		{name: "override no namespace", policy: NamespaceOverride, mapping: namespaced, declared: "other", want: "other"},
+		{name: "fill empty", policy: NamespaceFillEmpty, mapping: namespaced, ns: "app", want: "app"},
+		{name: "fill keeps declared", policy: NamespaceFillEmpty, mapping: namespaced, declared: "other", ns: "app", want: "other"},
+		{name: "reject mismatch", policy: NamespaceRejectMismatch, mapping: namespaced, declared: "other", ns: "app", wantErr: true},
+		{name: "reject same", policy: NamespaceRejectMismatch, mapping: namespaced, declared: "app", ns: "app", want: "app"},
+		{name: "reject fills empty", policy: NamespaceRejectMismatch, mapping: namespaced, ns: "app", want: "app"},
+		{name: "cluster scoped", policy: NamespaceRejectMismatch, mapping: cluster, declared: "other", ns: "app", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &K8{}
+			m.SetNamespacePolicy(tt.policy)
+			obj := &unstructured.Unstructured{}
+			obj.SetKind("ConfigMap")
+			obj.SetName("web")
+			obj.SetNamespace(tt.declared)
+			err := m.applyNamespacePolicy(obj, tt.mapping, tt.ns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyNamespacePolicy() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && obj.GetNamespace() != tt.want {
+				t.Fatalf("applyNamespacePolicy() namespace = %q, want %q", obj.GetNamespace(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitYamlDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{name: "two documents", data: "a: 1\n---\nb: 2\n", want: []string{"a: 1\n", "b: 2\n"}},
+		{name: "leading separator", data: "---\na: 1\n", want: []string{"", "a: 1\n"}},
+		{name: "separator with trailing space", data: "a: 1\n--- \nb: 2", want: []string{"a: 1\n", "b: 2"}},
+		{name: "last line separator", data: "a: 1\n---", want: []string{"a: 1\n", ""}},
+		{name: "indented separator", data: "a: |\n  x\n  ---\n  y\n", want: []string{"a: |\n  x\n  ---\n  y\n"}},
+		{name: "separator inside a line", data: "a: x---\nb: 2\n", want: []string{"a: x---\nb: 2\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitYamlDocuments(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("splitYamlDocuments() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitK8FileRoundTrip(t *testing.T) {
+	script := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "script", "namespace": "app"},
+		"data": map[string]interface{}{
+			"run.sh":   "#!/bin/sh\necho start\n---\necho end\n",
+			"doc.yaml": "a: 1\n---\nb: 2\n",
+		},
+	}}
+	other := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "other", "namespace": "app"},
+		"data":       map[string]interface{}{"a": "1"},
+	}}
+
+	var docs []string
+	for _, obj := range []*unstructured.Unstructured{script, other} {
+		NeatObject(obj)
+		out, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, string(out))
+	}
+	m := &K8{}
+	parts := m.splitK8File([]byte(strings.Join(docs, "---\n")), "app", false)
+	if len(parts) != 2 {
+		t.Fatalf("splitK8File() = %d parts, want 2: %q", len(parts), parts)
+	}
+	for i, want := range []*unstructured.Unstructured{script, other} {
+		got := testObject(t, parts[i])
+		if !reflect.DeepEqual(got.Object, want.Object) {
+			t.Fatalf("part %d = %v, want %v", i, got.Object, want.Object)
+		}
+	}
+}
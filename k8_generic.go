@@ -0,0 +1,239 @@
+package go_k8_helm
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	memory "k8s.io/client-go/discovery/cached"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// ResolveKind finds the rest mapping for a kind
+// kind: a kind, resource or short name e.g. Deployment, deploy, ingress, certificates.cert-manager.io or deployments.v1.apps
+// return: the rest mapping, error
+func (m *K8) ResolveKind(kind string) (*meta.RESTMapping, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	cached := memory.NewMemCacheClient(dc)
+	mapper := restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cached), cached)
+
+	gvk := schema.GroupVersionKind{}
+	full_gvr, group_resource := schema.ParseResourceArg(strings.ToLower(kind))
+	if full_gvr != nil {
+		gvk, _ = mapper.KindFor(*full_gvr)
+	}
+	if gvk.Empty() {
+		gvk, err = mapper.KindFor(group_resource.WithVersion(""))
+		if err != nil {
+			return nil, fmt.Errorf("unknown kind %s: %w", kind, err)
+		}
+	}
+	return mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+// resolveGVK finds the rest mapping for a group version kind
+// gvk: the group version kind
+// return: the rest mapping, error
+func (m *K8) resolveGVK(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	return m.restMapping(&gvk)
+}
+
+// mappingResource returns the dynamic resource interface for a rest mapping
+// mapping: the rest mapping
+// ns: namespace, empty means all namespaces for a list
+// return: the resource interface, error
+func (m *K8) mappingResource(mapping *meta.RESTMapping, ns string) (dynamic.ResourceInterface, error) {
+	dyn, err := dynamic.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return dyn.Resource(mapping.Resource), nil
+	}
+	return dyn.Resource(mapping.Resource).Namespace(ns), nil
+}
+
+// Get gets any object by kind
+// kind: a kind, resource or short name e.g. deploy, ingress or certificates.cert-manager.io
+// ns: namespace, ignored for cluster-scoped kinds
+// name: name of the object
+// return: *unstructured.Unstructured, error
+func (m *K8) Get(kind string, ns string, name string) (*unstructured.Unstructured, error) {
+	mapping, err := m.ResolveKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	return m.getMapping(mapping, ns, name)
+}
+
+// GetGVK gets any object by group version kind
+// gvk: the group version kind
+// ns: namespace, ignored for cluster-scoped kinds
+// name: name of the object
+// return: *unstructured.Unstructured, error
+func (m *K8) GetGVK(gvk schema.GroupVersionKind, ns string, name string) (*unstructured.Unstructured, error) {
+	mapping, err := m.resolveGVK(gvk)
+	if err != nil {
+		return nil, err
+	}
+	return m.getMapping(mapping, ns, name)
+}
+
+// getMapping gets an object using a rest mapping
+func (m *K8) getMapping(mapping *meta.RESTMapping, ns string, name string) (*unstructured.Unstructured, error) {
+	if ns == "" {
+		ns = "default"
+	}
+	dr, err := m.mappingResource(mapping, ns)
+	if err != nil {
+		return nil, err
+	}
+	return dr.Get(m.ctx, name, metav1.GetOptions{})
+}
+
+// List lists any kind
+// kind: a kind, resource or short name e.g. deploy, ingress or certificates.cert-manager.io
+// ns: namespace, empty for all namespaces, ignored for cluster-scoped kinds
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: *unstructured.UnstructuredList, error
+func (m *K8) List(kind string, ns string, opts ...ListOption) (*unstructured.UnstructuredList, error) {
+	mapping, err := m.ResolveKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	return m.listMapping(mapping, ns, opts)
+}
+
+// ListGVK lists any group version kind
+// gvk: the group version kind
+// ns: namespace, empty for all namespaces, ignored for cluster-scoped kinds
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: *unstructured.UnstructuredList, error
+func (m *K8) ListGVK(gvk schema.GroupVersionKind, ns string, opts ...ListOption) (*unstructured.UnstructuredList, error) {
+	mapping, err := m.resolveGVK(gvk)
+	if err != nil {
+		return nil, err
+	}
+	return m.listMapping(mapping, ns, opts)
+}
+
+// listMapping lists objects using a rest mapping
+func (m *K8) listMapping(mapping *meta.RESTMapping, ns string, opts []ListOption) (*unstructured.UnstructuredList, error) {
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	dr, err := m.mappingResource(mapping, ns)
+	if err != nil {
+		return nil, err
+	}
+	return dr.List(m.ctx, list_options)
+}
+
+// Delete deletes any object by kind
+// kind: a kind, resource or short name e.g. deploy, ingress or certificates.cert-manager.io
+// ns: namespace, ignored for cluster-scoped kinds
+// name: name of the object
+// opts: delete options e.g. OptionDeleteWait
+// return: error
+func (m *K8) Delete(kind string, ns string, name string, opts ...DeleteOption) error {
+	mapping, err := m.ResolveKind(kind)
+	if err != nil {
+		return err
+	}
+	return m.deleteMapping(mapping, ns, name, opts)
+}
+
+// DeleteGVK deletes any object by group version kind
+// gvk: the group version kind
+// ns: namespace, ignored for cluster-scoped kinds
+// name: name of the object
+// opts: delete options e.g. OptionDeleteWait
+// return: error
+func (m *K8) DeleteGVK(gvk schema.GroupVersionKind, ns string, name string, opts ...DeleteOption) error {
+	mapping, err := m.resolveGVK(gvk)
+	if err != nil {
+		return err
+	}
+	return m.deleteMapping(mapping, ns, name, opts)
+}
+
+// deleteMapping deletes an object using a rest mapping
+func (m *K8) deleteMapping(mapping *meta.RESTMapping, ns string, name string, opts []DeleteOption) error {
+	if ns == "" {
+		ns = "default"
+	}
+	dr, err := m.mappingResource(mapping, ns)
+	if err != nil {
+		return err
+	}
+	return dynamicDeleteAndWait(dr, name, nil, opts)
+}
+
+// FromUnstructured decodes an unstructured object into a typed struct
+// e.g. FromUnstructured[appsv1.Deployment](obj)
+// obj: the unstructured object
+// return: the typed object, error
+func FromUnstructured[T any](obj *unstructured.Unstructured) (*T, error) {
+	typed := new(T)
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed)
+	if err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
+
+// FromUnstructuredList decodes an unstructured list into a slice of typed structs
+// e.g. FromUnstructuredList[appsv1.Deployment](list)
+// list: the unstructured list
+// return: the typed objects, error
+func FromUnstructuredList[T any](list *unstructured.UnstructuredList) ([]T, error) {
+	items := make([]T, 0, len(list.Items))
+	for i := range list.Items {
+		typed, err := FromUnstructured[T](&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *typed)
+	}
+	return items, nil
+}
+
+// GetTyped gets any object by kind and decodes it into a typed struct
+// e.g. GetTyped[batchv1.Job](k8, "job", "default", "migrate")
+// m: the k8 connection
+// kind: a kind, resource or short name
+// ns: namespace
+// name: name of the object
+// return: the typed object, error
+func GetTyped[T any](m *K8, kind string, ns string, name string) (*T, error) {
+	obj, err := m.Get(kind, ns, name)
+	if err != nil {
+		return nil, err
+	}
+	return FromUnstructured[T](obj)
+}
+
+// ListTyped lists any kind and decodes the items into typed structs
+// e.g. ListTyped[networkingv1.Ingress](k8, "ingress", "default")
+// m: the k8 connection
+// kind: a kind, resource or short name
+// ns: namespace, empty for all namespaces
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: the typed objects, error
+func ListTyped[T any](m *K8, kind string, ns string, opts ...ListOption) ([]T, error) {
+	list, err := m.List(kind, ns, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return FromUnstructuredList[T](list)
+}
@@ -127,6 +127,7 @@ func (m *K8) UninstallHelmChart(release_name string, namespace string) error {
 }
 
 // DeployHelmChart deploys a helm chart
+// The mutation and policy rules are applied to the rendered manifest
 // chart_path is the path to the chart to deploy
 // release_name is the name of the release to deploy
 // namespace is the namespace to deploy the release to
@@ -157,6 +158,7 @@ func (m *K8) DeployHelmChart(chart_path string, release_name string, namespace s
 	client := action.NewInstall(actionConfig)
 	client.Namespace = nameSpace
 	client.ReleaseName = releaseName
+	client.PostRenderer = m.postRenderer(nameSpace)
 
 	ch_path, err := client.LocateChart(chartPath, settings)
 	if err != nil {
@@ -182,6 +184,7 @@ func (m *K8) DeployHelmChart(chart_path string, release_name string, namespace s
 }
 
 // UpgradeHelmChart upgrades a helm chart
+// The mutation and policy rules are applied to the rendered manifest
 // chart_path is the path to the chart to upgrade
 // release_name is the name of the release to upgrade
 // namespace is the namespace to upgrade the release to
@@ -211,6 +214,7 @@ func (m *K8) UpgradeHelmChart(chart_path string, release_name string, namespace
 
 	client := action.NewUpgrade(actionConfig)
 	client.Namespace = nameSpace
+	client.PostRenderer = m.postRenderer(nameSpace)
 
 	ch_path, err := client.LocateChart(chartPath, settings)
 	if err != nil {
@@ -0,0 +1,162 @@
+package go_k8_helm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetIngresses gets the urls served by ingresses with the load balancer address
+// and whether the backend services and tls secrets exist
+// A lookup that fails for another reason than not found e.g. forbidden is reported in the error field
+// The scheme is https when the host is in the tls section
+// A rule without a host uses the first load balancer address
+// ns: namespace
+// regex_ingress_name: regex to match the ingress name
+// opts: list options e.g. OptionListLabelSelector("app.kubernetes.io/instance=web")
+// return: []IngressDetails, error
+func (m *K8) GetIngresses(ns string, regex_ingress_name string, opts ...ListOption) ([]IngressDetails, error) {
+	name_regex, err := regexp.Compile(regex_ingress_name)
+	if err != nil {
+		return nil, err
+	}
+
+	//**********************
+	// creates the clientset
+	//**********************
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ingresses, err := client_set.NetworkingV1().Ingresses(ns).List(context.TODO(), list_options)
+	if err != nil {
+		return nil, err
+	}
+
+	// cache the lookups as many rules share a backend
+	type lookup struct {
+		exists bool
+		err    string
+	}
+	services := map[string]lookup{}
+	serviceExists := func(ns string, name string) (bool, string) {
+		key := ns + "/" + name
+		if l, found := services[key]; found {
+			return l.exists, l.err
+		}
+		_, err := client_set.CoreV1().Services(ns).Get(context.TODO(), name, metav1.GetOptions{})
+		services[key] = lookup{exists: err == nil, err: lookupError(err)}
+		return services[key].exists, services[key].err
+	}
+
+	var details []IngressDetails
+	for _, ing := range ingresses.Items {
+		if !name_regex.MatchString(ing.Name) {
+			continue
+		}
+		d := IngressDetails{Name: ing.Name, Namespace: ing.Namespace}
+		if ing.Spec.IngressClassName != nil {
+			d.ClassName = *ing.Spec.IngressClassName
+		}
+		for _, lb := range ing.Status.LoadBalancer.Ingress {
+			if lb.Hostname != "" {
+				d.Addresses = append(d.Addresses, lb.Hostname)
+			} else if lb.IP != "" {
+				d.Addresses = append(d.Addresses, lb.IP)
+			}
+		}
+
+		//*******************
+		//Check the tls secrets
+		//*******************
+		tls_hosts := map[string]bool{}
+		tls_all := false
+		for _, tls := range ing.Spec.TLS {
+			t := IngressTLS{SecretName: tls.SecretName, Hosts: tls.Hosts}
+			if tls.SecretName != "" {
+				_, err := client_set.CoreV1().Secrets(ing.Namespace).Get(context.TODO(), tls.SecretName, metav1.GetOptions{})
+				t.Exists = err == nil
+				t.Error = lookupError(err)
+			}
+			for _, h := range tls.Hosts {
+				tls_hosts[h] = true
+			}
+			if len(tls.Hosts) == 0 {
+				tls_all = true
+			}
+			d.TLS = append(d.TLS, t)
+		}
+
+		address := ""
+		if len(d.Addresses) > 0 {
+			address = d.Addresses[0]
+		}
+		addURL := func(host string, path string, backend *networkingv1.IngressBackend) {
+			u := IngressURL{Host: host, Path: path}
+			if u.Host == "" {
+				u.Host = address
+			}
+			if u.Path == "" {
+				u.Path = "/"
+			}
+			scheme := "http"
+			if tls_all || tls_hosts[host] || matchesWildcard(tls_hosts, host) {
+				scheme = "https"
+			}
+			if u.Host != "" {
+				u.URL = fmt.Sprintf("%s://%s%s", scheme, u.Host, u.Path)
+			}
+			if backend != nil && backend.Service != nil {
+				u.Service = backend.Service.Name
+				if backend.Service.Port.Name != "" {
+					u.ServicePort = backend.Service.Port.Name
+				} else {
+					u.ServicePort = strconv.Itoa(int(backend.Service.Port.Number))
+				}
+				u.ServiceExists, u.ServiceError = serviceExists(ing.Namespace, u.Service)
+			}
+			d.URLs = append(d.URLs, u)
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, p := range rule.HTTP.Paths {
+				backend := p.Backend
+				addURL(rule.Host, p.Path, &backend)
+			}
+		}
+		if ing.Spec.DefaultBackend != nil {
+			addURL("", "/", ing.Spec.DefaultBackend)
+		}
+		details = append(details, d)
+	}
+	return details, nil
+}
+
+// lookupError returns the error of a lookup, empty when it succeeded or the object was not found
+func lookupError(err error) string {
+	if err == nil || apierrors.IsNotFound(err) {
+		return ""
+	}
+	return err.Error()
+}
+
+// matchesWildcard returns true if the host matches a wildcard host e.g. *.example.com
+func matchesWildcard(hosts map[string]bool, host string) bool {
+	_, domain, found := strings.Cut(host, ".")
+	return found && hosts["*."+domain]
+}
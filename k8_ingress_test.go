@@ -0,0 +1,46 @@
+package go_k8_helm
+
+import (
+	"errors"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestLookupError(t *testing.T) {
+	resource := schema.GroupResource{Resource: "secrets"}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "found", err: nil},
+		{name: "not found", err: apierrors.NewNotFound(resource, "tls")},
+		{name: "forbidden", err: apierrors.NewForbidden(resource, "tls", errors.New("rbac")), want: true},
+		{name: "timeout", err: apierrors.NewTimeoutError("slow", 1), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lookupError(tt.err); (got != "") != tt.want {
+				t.Fatalf("lookupError() = %q", got)
+			}
+		})
+	}
+}
+
+func TestMatchesWildcard(t *testing.T) {
+	hosts := map[string]bool{"*.example.com": true}
+	tests := map[string]bool{
+		"www.example.com": true,
+		"example.com":     false,
+		"a.b.example.com": false,
+		"www.example.org": false,
+		"localhost":       false,
+	}
+	for host, want := range tests {
+		if got := matchesWildcard(hosts, host); got != want {
+			t.Errorf("matchesWildcard(%q) = %t, want %t", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package go_k8_helm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// JobCleanup is when a job is deleted after it has run
+type JobCleanup string
+
+// The cleanup policies
+const (
+	JobCleanupAlways    JobCleanup = "always"
+	JobCleanupOnSuccess JobCleanup = "on-success"
+	JobCleanupNever     JobCleanup = "never"
+)
+
+// JobSpec is a simple job that runs one container
+// Name is used as the generate name when it ends with - otherwise as the name
+// BackoffLimit is the number of retries, nil uses the server default of 6
+type JobSpec struct {
+	Name           string            `json:"name" yaml:"name"`
+	Image          string            `json:"image" yaml:"image"`
+	Command        []string          `json:"command" yaml:"command"`
+	Args           []string          `json:"args" yaml:"args"`
+	Env            map[string]string `json:"env" yaml:"env"`
+	BackoffLimit   *int32            `json:"backoff_limit" yaml:"backoff_limit"`
+	ServiceAccount string            `json:"service_account" yaml:"service_account"`
+}
+
+// JobOptions are the options used when running a job
+// Timeout is how long to wait for the job, 0 waits for 10 minutes
+// Cleanup is when the job is deleted, empty is on-success
+// LogWriter streams the pod logs as they run, when nil the logs are collected in the result
+type JobOptions struct {
+	Timeout   time.Duration
+	Cleanup   JobCleanup
+	LogWriter io.Writer
+}
+
+// JobOption is the option for running a job
+type JobOption func(*JobOptions)
+
+// OptionJobTimeout is the option for how long to wait for the job
+func OptionJobTimeout(timeout time.Duration) JobOption {
+	return func(o *JobOptions) {
+		o.Timeout = timeout
+	}
+}
+
+// OptionJobCleanup is the option for when the job is deleted
+// cleanup: JobCleanupAlways, JobCleanupOnSuccess or JobCleanupNever
+func OptionJobCleanup(cleanup JobCleanup) JobOption {
+	return func(o *JobOptions) {
+		o.Cleanup = cleanup
+	}
+}
+
+// OptionJobLogWriter is the option to stream the pod logs to a writer
+// Each line is prefixed with the pod name
+func OptionJobLogWriter(w io.Writer) JobOption {
+	return func(o *JobOptions) {
+		o.LogWriter = w
+	}
+}
+
+// JobPodResult is the result of one pod of a job
+// ExitCodes is container name to exit code for the containers that have terminated
+type JobPodResult struct {
+	Pod       string           `json:"pod" yaml:"pod"`
+	Phase     string           `json:"phase" yaml:"phase"`
+	ExitCodes map[string]int32 `json:"exit_codes" yaml:"exit_codes"`
+	Logs      string           `json:"logs,omitempty" yaml:"logs,omitempty"`
+}
+
+// JobResult is the result of running a job
+type JobResult struct {
+	Name      string         `json:"name" yaml:"name"`
+	Namespace string         `json:"namespace" yaml:"namespace"`
+	Succeeded bool           `json:"succeeded" yaml:"succeeded"`
+	Reason    string         `json:"reason" yaml:"reason"`
+	Message   string         `json:"message" yaml:"message"`
+	Attempts  int32          `json:"attempts" yaml:"attempts"`
+	Pods      []JobPodResult `json:"pods" yaml:"pods"`
+}
+
+// RunJob creates a job from a manifest, waits for it to complete or fail and collects the pod results
+// The job is deleted according to the cleanup policy
+// ns: namespace
+// manifest: the job yaml
+// opts: job options e.g. OptionJobTimeout(time.Minute)
+// return: *JobResult, error if the job could not run, failed or timed out
+func (m *K8) RunJob(ns string, manifest string, opts ...JobOption) (*JobResult, error) {
+	job := &batchv1.Job{}
+	if err := yaml.Unmarshal([]byte(manifest), job); err != nil {
+		return nil, fmt.Errorf("invalid job manifest: %w", err)
+	}
+	if job.Kind != "" && job.Kind != "Job" {
+		return nil, fmt.Errorf("manifest is a %s not a Job", job.Kind)
+	}
+	return m.runJob(ns, job, opts)
+}
+
+// RunJobSpec creates a job that runs an image, waits for it to complete or fail and collects the pod results
+// The job is deleted according to the cleanup policy
+// ns: namespace
+// spec: the image and command to run
+// opts: job options e.g. OptionJobTimeout(time.Minute)
+// return: *JobResult, error if the job could not run, failed or timed out
+func (m *K8) RunJobSpec(ns string, spec JobSpec, opts ...JobOption) (*JobResult, error) {
+	if spec.Image == "" {
+		return nil, errors.New("image is required")
+	}
+	container := v1.Container{
+		Name:    "job",
+		Image:   spec.Image,
+		Command: spec.Command,
+		Args:    spec.Args,
+	}
+	var keys []string
+	for k := range spec.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		container.Env = append(container.Env, v1.EnvVar{Name: k, Value: spec.Env[k]})
+	}
+
+	job := &batchv1.Job{
+		Spec: batchv1.JobSpec{
+			BackoffLimit: spec.BackoffLimit,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					RestartPolicy:      v1.RestartPolicyNever,
+					ServiceAccountName: spec.ServiceAccount,
+					Containers:         []v1.Container{container},
+				},
+			},
+		},
+	}
+	name := spec.Name
+	if name == "" {
+		name = "job-"
+	}
+	if name[len(name)-1] == '-' {
+		job.GenerateName = name
+	} else {
+		job.Name = name
+	}
+	return m.runJob(ns, job, opts)
+}
+
+// runJob creates the job, waits for it and cleans up
+func (m *K8) runJob(ns string, job *batchv1.Job, opts []JobOption) (*JobResult, error) {
+	if ns == "" {
+		ns = "default"
+	}
+	job.Namespace = ns
+
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	created, err := client_set.BatchV1().Jobs(ns).Create(context.TODO(), job, metav1.CreateOptions{DryRun: m.dryRunOptions()})
+	if err != nil {
+		return nil, err
+	}
+	if m.dry_run {
+		return &JobResult{Name: created.Name, Namespace: ns}, nil
+	}
+	log.Printf("Info: Created Job(%s) in Namespace(%s)\n", created.Name, ns)
+	return m.WaitForJob(ns, created.Name, opts...)
+}
+
+// WaitForJob waits for a job to complete or fail and collects the pod results
+// The job is deleted according to the cleanup policy
+// ns: namespace
+// name: name of the job e.g. from TriggerCronJob
+// opts: job options e.g. OptionJobTimeout(time.Minute)
+// return: *JobResult, error if the job failed or timed out
+func (m *K8) WaitForJob(ns string, name string, opts ...JobOption) (*JobResult, error) {
+	o := &JobOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 10 * time.Minute
+	}
+	if o.Cleanup == "" {
+		o.Cleanup = JobCleanupOnSuccess
+	}
+
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	return m.waitForJob(client_set, ns, name, o)
+}
+
+// waitForJob waits for the job with the client
+func (m *K8) waitForJob(client_set kubernetes.Interface, ns string, name string, o *JobOptions) (*JobResult, error) {
+	jobs := client_set.BatchV1().Jobs(ns)
+	created, err := jobs.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result := &JobResult{Name: created.Name, Namespace: ns}
+
+	backoff_limit := int32(6)
+	if created.Spec.BackoffLimit != nil {
+		backoff_limit = *created.Spec.BackoffLimit
+	}
+
+	//*************************************
+	//Stream the logs of the pods as they run
+	//*************************************
+	ctx, cancel := context.WithCancel(context.Background())
+	var streams sync.WaitGroup
+	streaming := map[string]bool{}
+	writer := &lockedWriter{w: o.LogWriter}
+	// job-name also matches the pods of an earlier job with the same name
+	selector := "controller-uid=" + string(created.UID)
+
+	var failed_attempts int32
+	err = wait.PollImmediate(2*time.Second, o.Timeout, func() (bool, error) {
+		current, err := jobs.Get(context.TODO(), created.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if o.LogWriter != nil {
+			pods, err := client_set.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+			if err == nil {
+				for i := range pods.Items {
+					pod := &pods.Items[i]
+					if streaming[pod.Name] || pod.Status.Phase == v1.PodPending {
+						continue
+					}
+					streaming[pod.Name] = true
+					streams.Add(1)
+					go func(pod *v1.Pod) {
+						defer streams.Done()
+						streamPodLogs(ctx, client_set, pod, writer)
+					}(pod)
+				}
+			}
+		}
+
+		if current.Status.Failed > failed_attempts {
+			failed_attempts = current.Status.Failed
+			log.Printf("Info: Job(%s) attempt %d of %d failed\n", created.Name, failed_attempts, backoff_limit+1)
+		}
+		result.Attempts = current.Status.Failed + current.Status.Succeeded + current.Status.Active
+		for _, c := range current.Status.Conditions {
+			if c.Status != v1.ConditionTrue {
+				continue
+			}
+			switch c.Type {
+			case batchv1.JobComplete:
+				result.Succeeded = true
+				result.Reason, result.Message = c.Reason, c.Message
+				return true, nil
+			case batchv1.JobFailed:
+				result.Reason, result.Message = c.Reason, c.Message
+				return true, nil
+			}
+		}
+		if current.Status.Failed > backoff_limit {
+			// the failed condition can lag behind the pod count
+			result.Reason = "BackoffLimitExceeded"
+			result.Message = fmt.Sprintf("%d attempts failed", current.Status.Failed)
+			return true, nil
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		result.Reason = "Timeout"
+		result.Message = fmt.Sprintf("job did not finish in %s", o.Timeout)
+	}
+
+	//*********************
+	//Collect the pod results
+	//*********************
+	if err == nil || err == wait.ErrWaitTimeout {
+		// let the streams read the last lines before they are stopped
+		if o.LogWriter != nil {
+			done := make(chan struct{})
+			go func() { streams.Wait(); close(done) }()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+			}
+		}
+		result.Pods, _ = jobPodResults(client_set, ns, selector, o.LogWriter == nil)
+	}
+	cancel()
+	streams.Wait()
+
+	if o.Cleanup == JobCleanupAlways || (o.Cleanup == JobCleanupOnSuccess && result.Succeeded) {
+		del_err := deleteAndWait(created.Name, jobs.Delete, deleteAndWaitGetter(jobs.Get), []DeleteOption{OptionDeletePropagation(metav1.DeletePropagationBackground), OptionDeleteIgnoreNotFound(true)}, nil)
+		if del_err != nil {
+			log.Printf("Info: Unable to delete Job(%s) Error(%s)\n", created.Name, del_err.Error())
+		}
+	}
+
+	if err != nil && err != wait.ErrWaitTimeout {
+		return result, err
+	}
+	if !result.Succeeded {
+		return result, fmt.Errorf("job %s failed: %s %s", created.Name, result.Reason, result.Message)
+	}
+	return result, nil
+}
+
+// jobPodResults gets the phase, exit codes and optionally the logs of the pods of a job
+// The init containers are included as a failed migration is often in an init container
+func jobPodResults(client_set kubernetes.Interface, ns string, selector string, with_logs bool) ([]JobPodResult, error) {
+	pods, err := client_set.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].CreationTimestamp.Before(&pods.Items[j].CreationTimestamp)
+	})
+
+	var results []JobPodResult
+	for _, pod := range pods.Items {
+		r := JobPodResult{Pod: pod.Name, Phase: string(pod.Status.Phase), ExitCodes: map[string]int32{}}
+		statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, s := range statuses {
+			if s.State.Terminated != nil {
+				r.ExitCodes[s.Name] = s.State.Terminated.ExitCode
+			} else if s.LastTerminationState.Terminated != nil {
+				// a restarted container is waiting to run again
+				r.ExitCodes[s.Name] = s.LastTerminationState.Terminated.ExitCode
+			}
+		}
+		if with_logs {
+			var buf bytes.Buffer
+			for _, c := range podContainerNames(&pod) {
+				data, err := client_set.CoreV1().Pods(ns).GetLogs(pod.Name, &v1.PodLogOptions{Container: c}).DoRaw(context.TODO())
+				if err != nil {
+					continue
+				}
+				buf.Write(data)
+			}
+			r.Logs = buf.String()
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// podContainerNames returns the names of the init containers and containers of a pod in the order they run
+func podContainerNames(pod *v1.Pod) []string {
+	var names []string
+	for _, c := range pod.Spec.InitContainers {
+		names = append(names, c.Name)
+	}
+	for _, c := range pod.Spec.Containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// streamPodLogs follows the logs of every init container and container of a pod and writes them with the pod name
+func streamPodLogs(ctx context.Context, client_set kubernetes.Interface, pod *v1.Pod, w io.Writer) {
+	for _, c := range podContainerNames(pod) {
+		stream, err := client_set.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{Container: c, Follow: true}).Stream(ctx)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(stream)
+		for scanner.Scan() {
+			fmt.Fprintf(w, "[%s] %s\n", pod.Name, scanner.Text())
+		}
+		stream.Close()
+	}
+}
+
+// lockedWriter lets several pods write their logs to the same writer
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes to the writer while holding the lock
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,140 @@
+package go_k8_helm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testJob builds a finished job with the status and one pod whose init container failed
+func testJob(status batchv1.JobStatus, backoff_limit int32) (*batchv1.Job, *v1.Pod) {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "app", UID: types.UID("job-uid")},
+		Spec:       batchv1.JobSpec{BackoffLimit: &backoff_limit},
+		Status:     status,
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "migrate-abc", Namespace: "app", Labels: map[string]string{"controller-uid": "job-uid"}},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "schema"}},
+			Containers:     []v1.Container{{Name: "app"}},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodFailed,
+			InitContainerStatuses: []v1.ContainerStatus{{
+				Name:  "schema",
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 3}},
+			}},
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:  "app",
+				State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "PodInitializing"}},
+			}},
+		},
+	}
+	return job, pod
+}
+
+func TestWaitForJob(t *testing.T) {
+	complete := batchv1.JobStatus{Succeeded: 1, Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}}}
+	failed := batchv1.JobStatus{Failed: 2, Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Reason: "BackoffLimitExceeded"}}}
+	// the failed condition has not been set yet
+	lagging := batchv1.JobStatus{Failed: 2}
+
+	tests := []struct {
+		name          string
+		status        batchv1.JobStatus
+		cleanup       JobCleanup
+		wantSucceeded bool
+		wantReason    string
+		wantDeleted   bool
+	}{
+		{name: "complete on success", status: complete, cleanup: JobCleanupOnSuccess, wantSucceeded: true, wantDeleted: true},
+		{name: "complete never", status: complete, cleanup: JobCleanupNever, wantSucceeded: true},
+		{name: "failed on success", status: failed, cleanup: JobCleanupOnSuccess, wantReason: "BackoffLimitExceeded"},
+		{name: "failed always", status: failed, cleanup: JobCleanupAlways, wantReason: "BackoffLimitExceeded", wantDeleted: true},
+		{name: "backoff limit before condition", status: lagging, cleanup: JobCleanupOnSuccess, wantReason: "BackoffLimitExceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job, pod := testJob(tt.status, 1)
+			client_set := fake.NewSimpleClientset(job, pod)
+			m := &K8{}
+			result, err := m.waitForJob(client_set, "app", "migrate", &JobOptions{Timeout: 10 * time.Second, Cleanup: tt.cleanup})
+			if (err == nil) != tt.wantSucceeded || result.Succeeded != tt.wantSucceeded {
+				t.Fatalf("waitForJob() = %+v, %v", result, err)
+			}
+			if result.Reason != tt.wantReason || result.Attempts != tt.status.Failed+tt.status.Succeeded {
+				t.Fatalf("waitForJob() reason %q attempts %d", result.Reason, result.Attempts)
+			}
+
+			_, get_err := client_set.BatchV1().Jobs("app").Get(context.TODO(), "migrate", metav1.GetOptions{})
+			if deleted := apierrors.IsNotFound(get_err); deleted != tt.wantDeleted {
+				t.Fatalf("job deleted = %t, want %t (%v)", deleted, tt.wantDeleted, get_err)
+			}
+		})
+	}
+}
+
+func TestWaitForJobTimeout(t *testing.T) {
+	job, pod := testJob(batchv1.JobStatus{Active: 1}, 1)
+	client_set := fake.NewSimpleClientset(job, pod)
+	m := &K8{}
+	result, err := m.waitForJob(client_set, "app", "migrate", &JobOptions{Timeout: time.Second, Cleanup: JobCleanupAlways})
+	if err == nil || result.Reason != "Timeout" {
+		t.Fatalf("waitForJob() = %+v, %v", result, err)
+	}
+}
+
+func TestJobPodResults(t *testing.T) {
+	_, pod := testJob(batchv1.JobStatus{}, 1)
+	other := pod.DeepCopy()
+	other.Name = "other-job"
+	other.Labels = map[string]string{"controller-uid": "other-uid"}
+	client_set := fake.NewSimpleClientset(pod, other)
+
+	results, err := jobPodResults(client_set, "app", "controller-uid=job-uid", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Pod != "migrate-abc" || results[0].Phase != string(v1.PodFailed) {
+		t.Fatalf("jobPodResults() = %+v", results)
+	}
+	if code, found := results[0].ExitCodes["schema"]; !found || code != 3 {
+		t.Fatalf("init container exit code = %v", results[0].ExitCodes)
+	}
+	if _, found := results[0].ExitCodes["app"]; found {
+		t.Fatal("a container that did not run has an exit code")
+	}
+	// the fake client returns "fake logs" for each container
+	if strings.Count(results[0].Logs, "fake logs") != 2 {
+		t.Fatalf("logs = %q, want the init container and container logs", results[0].Logs)
+	}
+
+	results, err = jobPodResults(client_set, "app", "controller-uid=job-uid", false)
+	if err != nil || results[0].Logs != "" {
+		t.Fatalf("jobPodResults() without logs = %+v, %v", results, err)
+	}
+}
+
+func TestJobPodResultsRestarted(t *testing.T) {
+	_, pod := testJob(batchv1.JobStatus{}, 1)
+	pod.Status.InitContainerStatuses[0].State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+	pod.Status.InitContainerStatuses[0].LastTerminationState = v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 5}}
+	client_set := fake.NewSimpleClientset(pod)
+
+	results, err := jobPodResults(client_set, "app", "controller-uid=job-uid", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].ExitCodes["schema"] != 5 {
+		t.Fatalf("exit codes = %v, want the last termination", results[0].ExitCodes)
+	}
+}
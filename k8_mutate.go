@@ -0,0 +1,207 @@
+package go_k8_helm
+
+import (
+	"bytes"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// MutationSpec is the set of changes made to every object before it is applied
+// Labels and Annotations are added to each object and its pod template
+// RegistryMap maps an image prefix to a replacement prefix e.g. docker.io -> mirror.local/docker
+// Digests maps an image reference (after the registry rewrite) to a digest e.g. sha256:...
+type MutationSpec struct {
+	Labels      map[string]string `json:"labels" yaml:"labels"`
+	Annotations map[string]string `json:"annotations" yaml:"annotations"`
+	RegistryMap map[string]string `json:"registry_map" yaml:"registry_map"`
+	Digests     map[string]string `json:"digests" yaml:"digests"`
+}
+
+// Mutation returns the mutation
+func (m *K8) Mutation() *MutationSpec {
+	return m.mutation
+}
+
+// SetMutation sets the mutation
+// The mutation is used by ApplyYaml, ProcessK8File and as a helm post renderer
+// Set to nil to turn it off
+func (m *K8) SetMutation(mutation *MutationSpec) {
+	m.mutation = mutation
+}
+
+// OptionK8Mutation is the option for the mutation
+func OptionK8Mutation(mutation *MutationSpec) K8Option {
+	return func(h *K8) {
+		h.mutation = mutation
+	}
+}
+
+// MutateK8File applies the mutation to a k8 file
+// with multiple definitions separated with ---
+// file_data: file data
+// return: the mutated file, error
+func (m *K8) MutateK8File(file_data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for _, o := range m.splitK8File(file_data, "", false) {
+		if isEmptyYaml(o) {
+			continue
+		}
+		obj, _, err := decodeYaml(o)
+		if err != nil {
+			return nil, err
+		}
+		m.mutateObject(obj)
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString("---\n")
+		out.Write(data)
+	}
+	return out.Bytes(), nil
+}
+
+// mutateObject applies the mutation to an object
+// obj: the object to change
+func (m *K8) mutateObject(obj *unstructured.Unstructured) {
+	if m.mutation == nil {
+		return
+	}
+	mu := m.mutation
+
+	//**********************
+	//Labels and annotations
+	//**********************
+	obj.SetLabels(mergeMap(obj.GetLabels(), mu.Labels))
+	obj.SetAnnotations(mergeMap(obj.GetAnnotations(), mu.Annotations))
+	for _, path := range podTemplateMetadataPaths(obj) {
+		if len(mu.Labels) > 0 {
+			labels, _, _ := unstructured.NestedStringMap(obj.Object, append(path, "labels")...)
+			unstructured.SetNestedStringMap(obj.Object, mergeMap(labels, mu.Labels), append(path, "labels")...)
+		}
+		if len(mu.Annotations) > 0 {
+			annotations, _, _ := unstructured.NestedStringMap(obj.Object, append(path, "annotations")...)
+			unstructured.SetNestedStringMap(obj.Object, mergeMap(annotations, mu.Annotations), append(path, "annotations")...)
+		}
+	}
+
+	//**************************
+	//Rewrite and pin the images
+	//**************************
+	if len(mu.RegistryMap) == 0 && len(mu.Digests) == 0 {
+		return
+	}
+	for _, c := range podContainers(obj) {
+		image, ok := c["image"].(string)
+		if !ok || image == "" {
+			continue
+		}
+		c["image"] = mu.RewriteImage(image)
+	}
+}
+
+// RewriteImage rewrites an image reference using the registry map and pins it to a digest
+// The longest matching prefix in the registry map is used
+// Images without a registry are also matched as docker.io/library/name or docker.io/org/name
+// image: the image reference
+// return: the new image reference
+func (mu *MutationSpec) RewriteImage(image string) string {
+	//****************
+	//Rewrite registry
+	//****************
+	if prefix, ok := longestPrefix(mu.RegistryMap, image); ok {
+		image = mu.RegistryMap[prefix] + strings.TrimPrefix(image, prefix)
+	} else if full := normalizeImage(image); full != image {
+		if prefix, ok := longestPrefix(mu.RegistryMap, full); ok {
+			image = mu.RegistryMap[prefix] + strings.TrimPrefix(full, prefix)
+		}
+	}
+
+	//*************
+	//Pin to digest
+	//*************
+	if strings.Contains(image, "@") {
+		return image
+	}
+	if digest, ok := mu.Digests[image]; ok {
+		return imageRepository(image) + "@" + digest
+	}
+	return image
+}
+
+// longestPrefix finds the longest key in the map that is a path prefix of value
+// The prefix must be the whole value or end at a / so docker.io does not match docker.io.example.com/app
+// prefixes: the map of prefixes
+// value: the value to match
+// return: the prefix, true if found
+func longestPrefix(prefixes map[string]string, value string) (string, bool) {
+	found := ""
+	for prefix := range prefixes {
+		if prefix == "" || !strings.HasPrefix(value, prefix) || len(prefix) <= len(found) {
+			continue
+		}
+		if len(value) == len(prefix) || value[len(prefix)] == '/' || strings.HasSuffix(prefix, "/") {
+			found = prefix
+		}
+	}
+	return found, found != ""
+}
+
+// normalizeImage adds the implied docker hub registry to an image reference
+// image: the image reference e.g. nginx:1.23
+// return: the full image reference e.g. docker.io/library/nginx:1.23
+func normalizeImage(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return image
+	}
+	if len(parts) == 1 {
+		return "docker.io/library/" + image
+	}
+	return "docker.io/" + image
+}
+
+// imageRepository removes the tag and digest from an image reference
+// image: the image reference
+// return: the repository
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// mergeMap adds the values to the current map
+// current: the current map, can be nil
+// values: the values to add
+// return: the merged map, nil if both are empty
+func mergeMap(current map[string]string, values map[string]string) map[string]string {
+	if len(values) == 0 {
+		return current
+	}
+	if current == nil {
+		current = map[string]string{}
+	}
+	for k, v := range values {
+		current[k] = v
+	}
+	return current
+}
+
+// podTemplateMetadataPaths returns the paths to the metadata of the templates in a workload object
+// obj: the object
+// return: the metadata paths
+func podTemplateMetadataPaths(obj *unstructured.Unstructured) [][]string {
+	switch obj.GetKind() {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "ReplicationController", "Job":
+		return [][]string{{"spec", "template", "metadata"}}
+	case "CronJob":
+		return [][]string{{"spec", "jobTemplate", "metadata"}, {"spec", "jobTemplate", "spec", "template", "metadata"}}
+	}
+	return nil
+}
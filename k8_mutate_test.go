@@ -0,0 +1,98 @@
+package go_k8_helm
+
+import "testing"
+
+func TestLongestPrefix(t *testing.T) {
+	prefixes := map[string]string{
+		"docker.io":           "",
+		"docker.io/library":   "",
+		"quay.io/":            "",
+		"registry.local:5000": "",
+	}
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "docker.io/library/nginx:1.23", want: "docker.io/library"},
+		{value: "docker.io/bitnami/redis", want: "docker.io"},
+		{value: "docker.io", want: "docker.io"},
+		{value: "docker.io.example.com/app", want: ""},
+		{value: "docker.iox/app", want: ""},
+		{value: "docker.io/libraryx/app", want: "docker.io"},
+		{value: "quay.io/org/app", want: "quay.io/"},
+		{value: "registry.local:5000/app", want: "registry.local:5000"},
+		{value: "registry.local:50001/app", want: ""},
+		{value: "gcr.io/app", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := longestPrefix(prefixes, tt.value)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Fatalf("longestPrefix(%q) = %q, %t, want %q", tt.value, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteImage(t *testing.T) {
+	mu := &MutationSpec{
+		RegistryMap: map[string]string{
+			"docker.io":   "mirror.local/docker",
+			"ghcr.io/org": "mirror.local/org",
+		},
+		Digests: map[string]string{
+			"mirror.local/docker/library/nginx:1.23": "sha256:abc",
+		},
+	}
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "nginx:1.23", want: "mirror.local/docker/library/nginx@sha256:abc"},
+		{image: "docker.io/library/nginx:1.23", want: "mirror.local/docker/library/nginx@sha256:abc"},
+		{image: "bitnami/redis:7", want: "mirror.local/docker/bitnami/redis:7"},
+		{image: "ghcr.io/org/app:v1", want: "mirror.local/org/app:v1"},
+		{image: "ghcr.io/organisation/app:v1", want: "ghcr.io/organisation/app:v1"},
+		{image: "docker.io.example.com/app:v1", want: "docker.io.example.com/app:v1"},
+		{image: "localhost/app", want: "localhost/app"},
+		{image: "nginx@sha256:def", want: "mirror.local/docker/library/nginx@sha256:def"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := mu.RewriteImage(tt.image); got != tt.want {
+				t.Fatalf("RewriteImage(%q) = %q, want %q", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeImage(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                 "docker.io/library/nginx",
+		"bitnami/redis:7":       "docker.io/bitnami/redis:7",
+		"quay.io/org/app":       "quay.io/org/app",
+		"localhost/app":         "localhost/app",
+		"registry:5000/app":     "registry:5000/app",
+		"docker.io/library/foo": "docker.io/library/foo",
+	}
+	for image, want := range tests {
+		if got := normalizeImage(image); got != want {
+			t.Errorf("normalizeImage(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
+
+func TestImageRepository(t *testing.T) {
+	tests := map[string]string{
+		"nginx:1.23":                  "nginx",
+		"registry:5000/app":           "registry:5000/app",
+		"registry:5000/app:v1":        "registry:5000/app",
+		"nginx@sha256:abc":            "nginx",
+		"docker.io/library/nginx:1.2": "docker.io/library/nginx",
+	}
+	for image, want := range tests {
+		if got := imageRepository(image); got != want {
+			t.Errorf("imageRepository(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
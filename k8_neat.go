@@ -0,0 +1,200 @@
+package go_k8_helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// podSpecDefaults are the values the server sets on a pod spec when they are not given
+var podSpecDefaults = map[string]interface{}{
+	"dnsPolicy":                     "ClusterFirst",
+	"restartPolicy":                 "Always",
+	"schedulerName":                 "default-scheduler",
+	"terminationGracePeriodSeconds": int64(30),
+	"enableServiceLinks":            true,
+	"preemptionPolicy":              "PreemptLowerPriority",
+	"priority":                      int64(0),
+}
+
+// containerDefaults are the values the server sets on a container when they are not given
+var containerDefaults = map[string]interface{}{
+	"terminationMessagePath":   "/dev/termination-log",
+	"terminationMessagePolicy": "File",
+}
+
+// GetClean gets any object by kind as clean yaml or json that can be applied again with ProcessK8File
+// The server populated fields, the default values and the last applied annotation are removed
+// kind: a kind, resource or short name e.g. deploy, ingress or certificates.cert-manager.io
+// ns: namespace, ignored for cluster-scoped kinds
+// name: name of the object
+// format: OutputYAML or OutputJSON
+// return: the manifest, error
+func (m *K8) GetClean(kind string, ns string, name string, format OutputFormat) ([]byte, error) {
+	obj, err := m.Get(kind, ns, name)
+	if err != nil {
+		return nil, err
+	}
+	NeatObject(obj)
+
+	switch format {
+	case OutputYAML, "":
+		return yaml.Marshal(obj.Object)
+	case OutputJSON:
+		return json.MarshalIndent(obj.Object, "", "  ")
+	}
+	return nil, fmt.Errorf("unsupported format %s use yaml or json", format)
+}
+
+// NeatObject removes the server populated fields and the default values from an object
+// obj: the object to clean
+func NeatObject(obj *unstructured.Unstructured) {
+	cleanObject(obj)
+
+	//*************************
+	//Remove the pod defaults
+	//*************************
+	if spec, _ := podSpec(obj); spec != nil {
+		removeDefaults(spec, podSpecDefaults)
+		for _, container := range podContainers(obj) {
+			removeDefaults(container, containerDefaults)
+			image, _ := container["image"].(string)
+			if policy, _ := container["imagePullPolicy"].(string); policy == defaultPullPolicy(image) {
+				delete(container, "imagePullPolicy")
+			}
+			ports, _ := container["ports"].([]interface{})
+			for _, p := range ports {
+				if port, ok := p.(map[string]interface{}); ok {
+					removeDefaults(port, map[string]interface{}{"protocol": "TCP"})
+				}
+			}
+		}
+	}
+
+	switch obj.GetKind() {
+	case "Deployment":
+		removeDefaults(obj.Object["spec"], map[string]interface{}{
+			"progressDeadlineSeconds": int64(600),
+			"revisionHistoryLimit":    int64(10),
+			"strategy": map[string]interface{}{
+				"type":          "RollingUpdate",
+				"rollingUpdate": map[string]interface{}{"maxSurge": "25%", "maxUnavailable": "25%"},
+			},
+		})
+	case "StatefulSet":
+		removeDefaults(obj.Object["spec"], map[string]interface{}{
+			"podManagementPolicy":  "OrderedReady",
+			"revisionHistoryLimit": int64(10),
+			"updateStrategy": map[string]interface{}{
+				"type":          "RollingUpdate",
+				"rollingUpdate": map[string]interface{}{"partition": int64(0)},
+			},
+			"persistentVolumeClaimRetentionPolicy": map[string]interface{}{"whenDeleted": "Retain", "whenScaled": "Retain"},
+		})
+	case "DaemonSet":
+		removeDefaults(obj.Object["spec"], map[string]interface{}{
+			"revisionHistoryLimit": int64(10),
+			"updateStrategy": map[string]interface{}{
+				"type":          "RollingUpdate",
+				"rollingUpdate": map[string]interface{}{"maxSurge": int64(0), "maxUnavailable": int64(1)},
+			},
+		})
+	case "Service":
+		spec, _ := obj.Object["spec"].(map[string]interface{})
+		removeDefaults(spec, map[string]interface{}{
+			"sessionAffinity":       "None",
+			"internalTrafficPolicy": "Cluster",
+			"ipFamilyPolicy":        "SingleStack",
+		})
+		if spec != nil {
+			if _, found := spec["ipFamilyPolicy"]; !found {
+				// the families are picked by the server for a single stack service
+				delete(spec, "ipFamilies")
+			}
+			ports, _ := spec["ports"].([]interface{})
+			for _, p := range ports {
+				port, ok := p.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				removeDefaults(port, map[string]interface{}{"protocol": "TCP"})
+				if reflect.DeepEqual(port["targetPort"], port["port"]) {
+					delete(port, "targetPort")
+				}
+			}
+		}
+	}
+	removeEmpty(obj.Object)
+}
+
+// defaultPullPolicy returns the pull policy the server uses for an image
+func defaultPullPolicy(image string) string {
+	name := image
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if strings.Contains(image, "@") {
+		return "IfNotPresent"
+	}
+	if !strings.Contains(name, ":") || strings.HasSuffix(name, ":latest") {
+		return "Always"
+	}
+	return "IfNotPresent"
+}
+
+// removeDefaults removes the fields that have their default value
+// Nested maps in the defaults are compared field by field
+// field: the map to clean
+// defaults: field name to default value
+func removeDefaults(field interface{}, defaults map[string]interface{}) {
+	values, ok := field.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, def := range defaults {
+		value, found := values[key]
+		if !found {
+			continue
+		}
+		if nested, ok := def.(map[string]interface{}); ok {
+			removeDefaults(value, nested)
+			if m, ok := value.(map[string]interface{}); ok && len(m) == 0 {
+				delete(values, key)
+			}
+			continue
+		}
+		if fmt.Sprint(value) == fmt.Sprint(def) {
+			delete(values, key)
+		}
+	}
+}
+
+// emptyFields are the fields removed when they are an empty map
+// other empty maps such as emptyDir: {} have a meaning and are kept
+var emptyFields = map[string]bool{
+	"resources": true, "securityContext": true, "labels": true, "annotations": true, "strategy": true, "updateStrategy": true,
+}
+
+// removeEmpty removes null values and empty emptyFields e.g. resources: {} and creationTimestamp: null
+// value: the map or slice to clean
+func removeEmpty(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			removeEmpty(field)
+			if field == nil {
+				delete(v, key)
+			} else if m, ok := field.(map[string]interface{}); ok && len(m) == 0 && emptyFields[key] {
+				delete(v, key)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			removeEmpty(item)
+		}
+	}
+}
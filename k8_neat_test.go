@@ -0,0 +1,236 @@
+package go_k8_helm
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestNeatObject(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "deployment",
+			in: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+  uid: 1234
+  resourceVersion: "99"
+  generation: 3
+  creationTimestamp: "2023-01-01T00:00:00Z"
+  managedFields: [{manager: kubectl}]
+  annotations:
+    deployment.kubernetes.io/revision: "3"
+    kubectl.kubernetes.io/last-applied-configuration: "{}"
+spec:
+  replicas: 2
+  progressDeadlineSeconds: 600
+  revisionHistoryLimit: 10
+  strategy:
+    type: RollingUpdate
+    rollingUpdate: {maxSurge: 25%, maxUnavailable: 25%}
+  template:
+    metadata:
+      creationTimestamp: null
+      labels: {app: web}
+    spec:
+      dnsPolicy: ClusterFirst
+      restartPolicy: Always
+      schedulerName: default-scheduler
+      terminationGracePeriodSeconds: 30
+      securityContext: {}
+      containers:
+      - name: web
+        image: nginx:1.23
+        imagePullPolicy: IfNotPresent
+        terminationMessagePath: /dev/termination-log
+        terminationMessagePolicy: File
+        resources: {}
+        ports:
+        - containerPort: 80
+          protocol: TCP
+      volumes:
+      - name: cache
+        emptyDir: {}
+status:
+  replicas: 2
+`,
+			want: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels: {app: web}
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.23
+        ports:
+        - containerPort: 80
+      volumes:
+      - name: cache
+        emptyDir: {}
+`,
+		},
+		{
+			name: "non default values are kept",
+			in: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  revisionHistoryLimit: 3
+  strategy:
+    type: RollingUpdate
+    rollingUpdate: {maxSurge: 1, maxUnavailable: 25%}
+  template:
+    spec:
+      restartPolicy: Always
+      terminationGracePeriodSeconds: 60
+      containers:
+      - name: web
+        image: nginx
+        imagePullPolicy: IfNotPresent
+`,
+			want: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  revisionHistoryLimit: 3
+  strategy:
+    rollingUpdate: {maxSurge: 1}
+  template:
+    spec:
+      terminationGracePeriodSeconds: 60
+      containers:
+      - name: web
+        image: nginx
+        imagePullPolicy: IfNotPresent
+`,
+		},
+		{
+			name: "latest image pull policy",
+			in: `
+apiVersion: v1
+kind: Pod
+metadata: {name: p}
+spec:
+  containers:
+  - name: a
+    image: registry:5000/app
+    imagePullPolicy: Always
+  - name: b
+    image: app@sha256:abc
+    imagePullPolicy: IfNotPresent
+`,
+			want: `
+apiVersion: v1
+kind: Pod
+metadata: {name: p}
+spec:
+  containers:
+  - name: a
+    image: registry:5000/app
+  - name: b
+    image: app@sha256:abc
+`,
+		},
+		{
+			name: "service",
+			in: `
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+spec:
+  type: ClusterIP
+  clusterIP: 10.0.0.10
+  clusterIPs: [10.0.0.10]
+  ipFamilies: [IPv4]
+  ipFamilyPolicy: SingleStack
+  sessionAffinity: None
+  internalTrafficPolicy: Cluster
+  ports:
+  - port: 80
+    targetPort: 80
+    protocol: TCP
+  - port: 443
+    targetPort: 8443
+    protocol: UDP
+`,
+			want: `
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+spec:
+  type: ClusterIP
+  ports:
+  - port: 80
+  - port: 443
+    targetPort: 8443
+    protocol: UDP
+`,
+		},
+		{
+			name: "headless dual stack service",
+			in: `
+apiVersion: v1
+kind: Service
+metadata: {name: db}
+spec:
+  clusterIP: None
+  ipFamilies: [IPv4, IPv6]
+  ipFamilyPolicy: PreferDualStack
+`,
+			want: `
+apiVersion: v1
+kind: Service
+metadata: {name: db}
+spec:
+  clusterIP: None
+  ipFamilies: [IPv4, IPv6]
+  ipFamilyPolicy: PreferDualStack
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := testObject(t, tt.in)
+			want := testObject(t, tt.want)
+			NeatObject(obj)
+			if !reflect.DeepEqual(obj.Object, want.Object) {
+				got, _ := yaml.Marshal(obj.Object)
+				t.Fatalf("NeatObject() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultPullPolicy(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                   "Always",
+		"nginx:latest":            "Always",
+		"nginx:1.23":              "IfNotPresent",
+		"registry:5000/app":       "Always",
+		"registry:5000/app:v1":    "IfNotPresent",
+		"nginx@sha256:abc":        "IfNotPresent",
+		"nginx:latest@sha256:abc": "IfNotPresent",
+	}
+	for image, want := range tests {
+		if got := defaultPullPolicy(image); got != want {
+			t.Errorf("defaultPullPolicy(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package go_k8_helm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/drain"
+)
+
+// nodeRolePrefix is the label prefix that gives a node its roles
+const nodeRolePrefix = "node-role.kubernetes.io/"
+
+// The drain progress states
+const (
+	DrainEvicting = "evicting"
+	DrainEvicted  = "evicted"
+	DrainSkipped  = "skipped"
+	// DrainDryRun is reported in dry run mode for a pod that would be evicted
+	DrainDryRun = "dry-run"
+)
+
+// DrainProgress is the progress of one pod during a drain
+type DrainProgress struct {
+	Node      string `json:"node" yaml:"node"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Pod       string `json:"pod" yaml:"pod"`
+	Status    string `json:"status" yaml:"status"`
+	Reason    string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// DrainOptions are the options used when draining a node
+// DeleteEmptyDir evicts pods that use emptyDir volumes, their data is lost
+// Force evicts pods that are not managed by a controller
+// GracePeriodSeconds is the pod grace period, negative uses the pod default
+// Timeout is how long to wait for the pods to go, 0 waits for 5 minutes
+// Progress is called for each pod, it is called concurrently from the eviction goroutines so it must be safe for concurrent use
+type DrainOptions struct {
+	DeleteEmptyDir     bool
+	Force              bool
+	GracePeriodSeconds int
+	Timeout            time.Duration
+	Progress           func(DrainProgress)
+}
+
+// DrainOption is the option for a drain
+type DrainOption func(*DrainOptions)
+
+// OptionDrainDeleteEmptyDir is the option to evict pods that use emptyDir volumes
+func OptionDrainDeleteEmptyDir(delete_empty_dir bool) DrainOption {
+	return func(o *DrainOptions) {
+		o.DeleteEmptyDir = delete_empty_dir
+	}
+}
+
+// OptionDrainForce is the option to evict pods that are not managed by a controller
+func OptionDrainForce(force bool) DrainOption {
+	return func(o *DrainOptions) {
+		o.Force = force
+	}
+}
+
+// OptionDrainGracePeriod is the option for the pod grace period in seconds
+func OptionDrainGracePeriod(seconds int) DrainOption {
+	return func(o *DrainOptions) {
+		o.GracePeriodSeconds = seconds
+	}
+}
+
+// OptionDrainTimeout is the option for how long to wait for the pods to go
+func OptionDrainTimeout(timeout time.Duration) DrainOption {
+	return func(o *DrainOptions) {
+		o.Timeout = timeout
+	}
+}
+
+// OptionDrainProgress is the option to get the progress of each pod
+func OptionDrainProgress(progress func(DrainProgress)) DrainOption {
+	return func(o *DrainOptions) {
+		o.Progress = progress
+	}
+}
+
+// GetNodes gets the nodes with their roles, conditions, capacity and allocatable resources
+// opts: list options e.g. OptionListLabelSelector("node-role.kubernetes.io/worker")
+// return: []NodeDetails, error
+func (m *K8) GetNodes(opts ...ListOption) ([]NodeDetails, error) {
+
+	//**********************
+	// creates the clientset
+	//**********************
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := client_set.CoreV1().Nodes().List(context.TODO(), list_options)
+	if err != nil {
+		return nil, err
+	}
+
+	var details []NodeDetails
+	for _, n := range nodes.Items {
+		d := NodeDetails{
+			Name:           n.Name,
+			Unschedulable:  n.Spec.Unschedulable,
+			KubeletVersion: n.Status.NodeInfo.KubeletVersion,
+			Addresses:      map[string]string{},
+			Capacity:       map[string]string{},
+			Allocatable:    map[string]string{},
+		}
+		for label := range n.Labels {
+			if strings.HasPrefix(label, nodeRolePrefix) {
+				d.Roles = append(d.Roles, strings.TrimPrefix(label, nodeRolePrefix))
+			}
+		}
+		sort.Strings(d.Roles)
+		for _, a := range n.Status.Addresses {
+			d.Addresses[string(a.Type)] = a.Address
+		}
+		for _, c := range n.Status.Conditions {
+			d.Conditions = append(d.Conditions, NodeCondition{Type: string(c.Type), Status: string(c.Status), Reason: c.Reason, Message: c.Message})
+			if c.Type == v1.NodeReady {
+				d.Ready = c.Status == v1.ConditionTrue
+			}
+		}
+		for k, v := range n.Status.Capacity {
+			d.Capacity[string(k)] = v.String()
+		}
+		for k, v := range n.Status.Allocatable {
+			d.Allocatable[string(k)] = v.String()
+		}
+		details = append(details, d)
+	}
+	return details, nil
+}
+
+// CordonNode marks a node unschedulable
+// name: name of the node
+// return: error
+func (m *K8) CordonNode(name string) error {
+	return m.cordonNode(name, true)
+}
+
+// UncordonNode marks a node schedulable
+// name: name of the node
+// return: error
+func (m *K8) UncordonNode(name string) error {
+	return m.cordonNode(name, false)
+}
+
+// cordonNode sets the node unschedulable flag
+func (m *K8) cordonNode(name string, cordon bool) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	return m.setUnschedulable(client_set, name, cordon)
+}
+
+// setUnschedulable sets the node unschedulable flag with the client
+func (m *K8) setUnschedulable(client_set kubernetes.Interface, name string, cordon bool) error {
+	node, err := client_set.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	helper := drain.NewCordonHelper(node)
+	if !helper.UpdateIfRequired(cordon) {
+		return nil
+	}
+	err, patch_err := helper.PatchOrReplace(client_set, m.dry_run)
+	if patch_err != nil {
+		return patch_err
+	}
+	if err != nil {
+		return err
+	}
+	if m.dry_run {
+		log.Printf("Info: Dry Run Set Node(%s) unschedulable to %t\n", name, cordon)
+		return nil
+	}
+	log.Printf("Info: Set Node(%s) unschedulable to %t\n", name, cordon)
+	return nil
+}
+
+// DrainNode cordons a node and evicts its pods with the Eviction API
+// PodDisruptionBudgets are respected, an eviction blocked by a budget is retried until the timeout
+// DaemonSet pods and mirror pods are skipped
+// With dry run set the node is cordoned with a server dry run and the pods that would be evicted are only reported
+// name: name of the node
+// opts: drain options e.g. OptionDrainDeleteEmptyDir(true)
+// return: error
+func (m *K8) DrainNode(name string, opts ...DrainOption) error {
+	o := &DrainOptions{GracePeriodSeconds: -1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 5 * time.Minute
+	}
+
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	return m.drainNode(client_set, name, o)
+}
+
+// drainNode cordons the node and evicts its pods with the client
+func (m *K8) drainNode(client_set kubernetes.Interface, name string, o *DrainOptions) error {
+	progress := func(p DrainProgress) {
+		p.Node = name
+		if o.Progress != nil {
+			o.Progress(p)
+		}
+	}
+
+	if err := m.setUnschedulable(client_set, name, true); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.Timeout)
+	defer cancel()
+	helper := &drain.Helper{
+		Ctx:                 ctx,
+		Client:              client_set,
+		Force:               o.Force,
+		GracePeriodSeconds:  o.GracePeriodSeconds,
+		IgnoreAllDaemonSets: true,
+		DeleteEmptyDirData:  o.DeleteEmptyDir,
+		Timeout:             o.Timeout,
+		Out:                 io.Discard,
+		ErrOut:              io.Discard,
+		OnPodDeletedOrEvicted: func(pod *v1.Pod, usingEviction bool) {
+			log.Printf("Info: Evicted Pod(%s) in Namespace(%s) from Node(%s)\n", pod.Name, pod.Namespace, name)
+			progress(DrainProgress{Namespace: pod.Namespace, Pod: pod.Name, Status: DrainEvicted})
+		},
+	}
+	list, errs := helper.GetPodsForDeletion(name)
+	if len(errs) > 0 {
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+		return fmt.Errorf("cannot drain node %s: %s", name, strings.Join(msgs, "; "))
+	}
+	if w := list.Warnings(); w != "" {
+		log.Printf("Info: Drain Node(%s) %s\n", name, w)
+	}
+
+	//*******************************
+	//Report the pods that are skipped
+	//*******************************
+	evict := map[string]bool{}
+	for _, pod := range list.Pods() {
+		evict[pod.Namespace+"/"+pod.Name] = true
+	}
+	on_node, err := client_set.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", name).String(),
+	})
+	if err == nil {
+		for _, pod := range on_node.Items {
+			if evict[pod.Namespace+"/"+pod.Name] {
+				continue
+			}
+			progress(DrainProgress{Namespace: pod.Namespace, Pod: pod.Name, Status: DrainSkipped, Reason: skipReason(&pod)})
+		}
+	}
+
+	//*****************************************************
+	//The drain helper has no dry run verifier so in dry run
+	//mode the pods are only reported
+	//*****************************************************
+	if m.dry_run {
+		for _, pod := range list.Pods() {
+			log.Printf("Info: Dry Run Evict Pod(%s) in Namespace(%s) from Node(%s)\n", pod.Name, pod.Namespace, name)
+			progress(DrainProgress{Namespace: pod.Namespace, Pod: pod.Name, Status: DrainDryRun})
+		}
+		return nil
+	}
+
+	for _, pod := range list.Pods() {
+		progress(DrainProgress{Namespace: pod.Namespace, Pod: pod.Name, Status: DrainEvicting})
+	}
+	if err := helper.DeleteOrEvictPods(list.Pods()); err != nil {
+		return fmt.Errorf("drain node %s: %w", name, err)
+	}
+	return nil
+}
+
+// skipReason returns why a drain leaves a pod on the node
+func skipReason(pod *v1.Pod) string {
+	if _, found := pod.Annotations[v1.MirrorPodAnnotationKey]; found {
+		return "mirror pod"
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+		return "daemonset pod"
+	}
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return "finished pod"
+	}
+	return "not evicted"
+}
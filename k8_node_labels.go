@@ -0,0 +1,240 @@
+package go_k8_helm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// NodeUpdate changes the labels or taints of a node
+// return: true if the node was changed, error
+type NodeUpdate func(node *v1.Node) (bool, error)
+
+// NodeAddLabels adds labels to a node
+// A label that already has a different value is an error, use NodeReplaceLabels to overwrite it
+// labels: the labels to add
+func NodeAddLabels(labels map[string]string) NodeUpdate {
+	return func(node *v1.Node) (bool, error) {
+		return setNodeLabels(node, labels, false)
+	}
+}
+
+// NodeReplaceLabels adds labels to a node and overwrites the value of the existing ones
+// labels: the labels to set
+func NodeReplaceLabels(labels map[string]string) NodeUpdate {
+	return func(node *v1.Node) (bool, error) {
+		return setNodeLabels(node, labels, true)
+	}
+}
+
+// NodeRemoveLabels removes labels from a node, missing labels are ignored
+// keys: the label keys to remove
+func NodeRemoveLabels(keys ...string) NodeUpdate {
+	return func(node *v1.Node) (bool, error) {
+		changed := false
+		for _, key := range keys {
+			if _, found := node.Labels[key]; found {
+				delete(node.Labels, key)
+				changed = true
+			}
+		}
+		return changed, nil
+	}
+}
+
+// NodeAddTaints adds taints to a node
+// A taint with the same key and effect but a different value is an error, use NodeReplaceTaints to overwrite it
+// taints: the taints to add e.g. ParseTaint("nvidia.com/gpu=true:NoSchedule")
+func NodeAddTaints(taints ...v1.Taint) NodeUpdate {
+	return func(node *v1.Node) (bool, error) {
+		return setNodeTaints(node, taints, false)
+	}
+}
+
+// NodeReplaceTaints adds taints to a node and overwrites the taints with the same key and effect
+// taints: the taints to set
+func NodeReplaceTaints(taints ...v1.Taint) NodeUpdate {
+	return func(node *v1.Node) (bool, error) {
+		return setNodeTaints(node, taints, true)
+	}
+}
+
+// NodeRemoveTaints removes taints from a node, missing taints are ignored
+// A taint without an effect removes all the taints with its key
+// taints: the taints to remove e.g. ParseTaint("dedicated:NoSchedule-")
+func NodeRemoveTaints(taints ...v1.Taint) NodeUpdate {
+	return func(node *v1.Node) (bool, error) {
+		var kept []v1.Taint
+		for _, t := range node.Spec.Taints {
+			remove := false
+			for _, r := range taints {
+				if t.Key == r.Key && (r.Effect == "" || t.Effect == r.Effect) {
+					remove = true
+					break
+				}
+			}
+			if !remove {
+				kept = append(kept, t)
+			}
+		}
+		changed := len(kept) != len(node.Spec.Taints)
+		node.Spec.Taints = kept
+		return changed, nil
+	}
+}
+
+// ParseTaint parses a taint in the kubectl format key[=value]:effect
+// A trailing - e.g. key:NoSchedule- or key- is accepted so the same spec can be used with NodeRemoveTaints
+// spec: the taint e.g. dedicated=tenant-a:NoSchedule
+// return: v1.Taint, error
+func ParseTaint(spec string) (v1.Taint, error) {
+	var taint v1.Taint
+	spec = strings.TrimSuffix(spec, "-")
+	key_value, effect, found := strings.Cut(spec, ":")
+	if found {
+		switch v1.TaintEffect(effect) {
+		case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+			taint.Effect = v1.TaintEffect(effect)
+		default:
+			return taint, fmt.Errorf("invalid taint effect %q in %q", effect, spec)
+		}
+	}
+	taint.Key, taint.Value, _ = strings.Cut(key_value, "=")
+	if taint.Key == "" {
+		return taint, fmt.Errorf("invalid taint %q, the key is empty", spec)
+	}
+	return taint, nil
+}
+
+// UpdateNode changes the labels and taints of a node, a conflict is retried
+// With dry run set the node is reported as changed but nothing is persisted
+// name: name of the node
+// updates: the changes e.g. NodeAddLabels(map[string]string{"pool": "gpu"}), NodeAddTaints(taint)
+// return: true if the node was changed, error
+func (m *K8) UpdateNode(name string, updates ...NodeUpdate) (bool, error) {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return false, err
+	}
+	return m.updateNode(client_set, name, updates)
+}
+
+// UpdateNodes changes the labels and taints of the nodes matching a selector
+// Each node is retried on conflict, the first error stops the update
+// With dry run set the nodes are reported as changed but nothing is persisted
+// updates: the changes e.g. NodeRemoveTaints(taint)
+// opts: list options to select the nodes, none selects all the nodes
+// return: the report of the nodes that were changed, error
+func (m *K8) UpdateNodes(updates []NodeUpdate, opts ...ListOption) (*NodeUpdateReport, error) {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := client_set.CoreV1().Nodes().List(context.TODO(), list_options)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &NodeUpdateReport{DryRun: m.dry_run}
+	for _, n := range nodes.Items {
+		updated, err := m.updateNode(client_set, n.Name, updates)
+		if err != nil {
+			return report, fmt.Errorf("node %s: %w", n.Name, err)
+		}
+		if updated {
+			report.Changed = append(report.Changed, n.Name)
+		}
+	}
+	return report, nil
+}
+
+// updateNode gets the node, applies the updates and updates it when changed
+func (m *K8) updateNode(client_set kubernetes.Interface, name string, updates []NodeUpdate) (bool, error) {
+	client := client_set.CoreV1().Nodes()
+	changed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		changed = false
+		node, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		update := false
+		for _, u := range updates {
+			c, err := u(node)
+			if err != nil {
+				return err
+			}
+			update = update || c
+		}
+		if !update {
+			return nil
+		}
+		_, err = client.Update(context.TODO(), node, metav1.UpdateOptions{DryRun: m.dryRunOptions()})
+		changed = err == nil
+		return err
+	})
+	if changed && m.dry_run {
+		log.Printf("Info: Dry Run Updated labels and taints of Node(%s)\n", name)
+	} else if changed {
+		log.Printf("Info: Updated labels and taints of Node(%s)\n", name)
+	}
+	return changed, err
+}
+
+// setNodeLabels sets labels on a node
+func setNodeLabels(node *v1.Node, labels map[string]string, overwrite bool) (bool, error) {
+	changed := false
+	for k, v := range labels {
+		current, found := node.Labels[k]
+		if found && current == v {
+			continue
+		}
+		if found && !overwrite {
+			return false, fmt.Errorf("label %s already has a value (%s)", k, current)
+		}
+		metav1.SetMetaDataLabel(&node.ObjectMeta, k, v)
+		changed = true
+	}
+	return changed, nil
+}
+
+// setNodeTaints sets taints on a node, a taint is identified by its key and effect
+func setNodeTaints(node *v1.Node, taints []v1.Taint, overwrite bool) (bool, error) {
+	changed := false
+	for _, t := range taints {
+		if t.Effect == "" {
+			return false, fmt.Errorf("taint %s has no effect", t.Key)
+		}
+		found := false
+		for i, current := range node.Spec.Taints {
+			if current.Key != t.Key || current.Effect != t.Effect {
+				continue
+			}
+			found = true
+			if current.Value == t.Value {
+				break
+			}
+			if !overwrite {
+				return false, fmt.Errorf("taint %s:%s already has a value (%s)", t.Key, t.Effect, current.Value)
+			}
+			node.Spec.Taints[i].Value = t.Value
+			changed = true
+			break
+		}
+		if !found {
+			node.Spec.Taints = append(node.Spec.Taints, t)
+			changed = true
+		}
+	}
+	return changed, nil
+}
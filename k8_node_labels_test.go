@@ -0,0 +1,151 @@
+package go_k8_helm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseTaint(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    v1.Taint
+		wantErr bool
+	}{
+		{spec: "dedicated=tenant-a:NoSchedule", want: v1.Taint{Key: "dedicated", Value: "tenant-a", Effect: v1.TaintEffectNoSchedule}},
+		{spec: "nvidia.com/gpu:NoExecute", want: v1.Taint{Key: "nvidia.com/gpu", Effect: v1.TaintEffectNoExecute}},
+		{spec: "spot=true:PreferNoSchedule", want: v1.Taint{Key: "spot", Value: "true", Effect: v1.TaintEffectPreferNoSchedule}},
+		{spec: "dedicated:NoSchedule-", want: v1.Taint{Key: "dedicated", Effect: v1.TaintEffectNoSchedule}},
+		{spec: "dedicated-", want: v1.Taint{Key: "dedicated"}},
+		{spec: "dedicated=a=b:NoSchedule", want: v1.Taint{Key: "dedicated", Value: "a=b", Effect: v1.TaintEffectNoSchedule}},
+		{spec: "dedicated:Sometimes", wantErr: true},
+		{spec: "=value:NoSchedule", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseTaint(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTaint() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseTaint() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetNodeTaints(t *testing.T) {
+	existing := []v1.Taint{{Key: "dedicated", Value: "a", Effect: v1.TaintEffectNoSchedule}}
+	tests := []struct {
+		name        string
+		taints      []v1.Taint
+		overwrite   bool
+		want        []v1.Taint
+		wantChanged bool
+		wantErr     bool
+	}{
+		{
+			name:        "add",
+			taints:      []v1.Taint{{Key: "gpu", Effect: v1.TaintEffectNoSchedule}},
+			want:        append(append([]v1.Taint{}, existing...), v1.Taint{Key: "gpu", Effect: v1.TaintEffectNoSchedule}),
+			wantChanged: true,
+		},
+		{
+			name:   "same value",
+			taints: []v1.Taint{{Key: "dedicated", Value: "a", Effect: v1.TaintEffectNoSchedule}},
+			want:   existing,
+		},
+		{
+			name:        "same key other effect",
+			taints:      []v1.Taint{{Key: "dedicated", Value: "b", Effect: v1.TaintEffectNoExecute}},
+			want:        append(append([]v1.Taint{}, existing...), v1.Taint{Key: "dedicated", Value: "b", Effect: v1.TaintEffectNoExecute}),
+			wantChanged: true,
+		},
+		{
+			name:    "different value",
+			taints:  []v1.Taint{{Key: "dedicated", Value: "b", Effect: v1.TaintEffectNoSchedule}},
+			wantErr: true,
+		},
+		{
+			name:        "replace value",
+			taints:      []v1.Taint{{Key: "dedicated", Value: "b", Effect: v1.TaintEffectNoSchedule}},
+			overwrite:   true,
+			want:        []v1.Taint{{Key: "dedicated", Value: "b", Effect: v1.TaintEffectNoSchedule}},
+			wantChanged: true,
+		},
+		{
+			name:    "no effect",
+			taints:  []v1.Taint{{Key: "gpu"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &v1.Node{Spec: v1.NodeSpec{Taints: append([]v1.Taint{}, existing...)}}
+			changed, err := setNodeTaints(node, tt.taints, tt.overwrite)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setNodeTaints() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if changed != tt.wantChanged || !reflect.DeepEqual(node.Spec.Taints, tt.want) {
+				t.Fatalf("setNodeTaints() = %t %+v, want %t %+v", changed, node.Spec.Taints, tt.wantChanged, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeRemoveTaints(t *testing.T) {
+	node := &v1.Node{Spec: v1.NodeSpec{Taints: []v1.Taint{
+		{Key: "dedicated", Effect: v1.TaintEffectNoSchedule},
+		{Key: "dedicated", Effect: v1.TaintEffectNoExecute},
+		{Key: "gpu", Effect: v1.TaintEffectNoSchedule},
+	}}}
+	changed, err := NodeRemoveTaints(v1.Taint{Key: "dedicated"}, v1.Taint{Key: "missing"})(node)
+	if err != nil || !changed {
+		t.Fatalf("NodeRemoveTaints() = %t, %v", changed, err)
+	}
+	if len(node.Spec.Taints) != 1 || node.Spec.Taints[0].Key != "gpu" {
+		t.Fatalf("taints = %+v", node.Spec.Taints)
+	}
+}
+
+func TestSetNodeLabels(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"pool": "cpu"}}}
+	if _, err := setNodeLabels(node, map[string]string{"pool": "gpu"}, false); err == nil {
+		t.Fatal("a label with a different value was overwritten")
+	}
+	changed, err := setNodeLabels(node, map[string]string{"pool": "gpu", "zone": "a"}, true)
+	if err != nil || !changed || node.Labels["pool"] != "gpu" || node.Labels["zone"] != "a" {
+		t.Fatalf("setNodeLabels() = %t, %v, labels %v", changed, err, node.Labels)
+	}
+	if changed, _ := setNodeLabels(node, map[string]string{"pool": "gpu"}, false); changed {
+		t.Fatal("an unchanged label was reported as changed")
+	}
+}
+
+func TestUpdateNode(t *testing.T) {
+	client_set := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+	m := &K8{}
+	update := NodeAddLabels(map[string]string{"pool": "gpu"})
+
+	changed, err := m.updateNode(client_set, "node-1", []NodeUpdate{update})
+	if err != nil || !changed {
+		t.Fatalf("updateNode() = %t, %v", changed, err)
+	}
+	node, err := client_set.CoreV1().Nodes().Get(context.TODO(), "node-1", metav1.GetOptions{})
+	if err != nil || node.Labels["pool"] != "gpu" {
+		t.Fatalf("node = %+v, %v", node, err)
+	}
+
+	changed, err = m.updateNode(client_set, "node-1", []NodeUpdate{update})
+	if err != nil || changed {
+		t.Fatalf("updateNode() of an unchanged node = %t, %v", changed, err)
+	}
+}
@@ -0,0 +1,183 @@
+package go_k8_helm
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testNodePod builds a pod on node-1 owned by a controller of the kind, an empty kind has no owner
+func testNodePod(name string, kind string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	if kind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: kind + "-owner", Controller: &controller}}
+	}
+	return pod
+}
+
+// testDrainClient builds a fake clientset with node-1, a replicaset pod, a daemonset pod and a mirror pod
+// The fake server supports the Eviction API and an eviction deletes the pod
+func testDrainClient() *fake.Clientset {
+	mirror := testNodePod("static", "")
+	mirror.Annotations = map[string]string{v1.MirrorPodAnnotationKey: "hash"}
+	objects := []runtime.Object{
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "DaemonSet-owner", Namespace: "default"}},
+		testNodePod("web", "ReplicaSet"),
+		testNodePod("agent", "DaemonSet"),
+		mirror,
+	}
+	client_set := fake.NewSimpleClientset(objects...)
+	client_set.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true},
+			{Name: "pods/eviction", Kind: "Eviction", Group: "policy", Version: "v1", Namespaced: true},
+		},
+	}}
+	client_set.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		return true, nil, client_set.Tracker().Delete(v1.SchemeGroupVersion.WithResource("pods"), eviction.Namespace, eviction.Name)
+	})
+	return client_set
+}
+
+// drainRecorder collects the drain progress, it is called from the eviction goroutines
+type drainRecorder struct {
+	lock     sync.Mutex
+	progress []DrainProgress
+}
+
+func (r *drainRecorder) record(p DrainProgress) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.progress = append(r.progress, p)
+}
+
+// statuses returns pod=status for each progress call sorted
+func (r *drainRecorder) statuses() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var out []string
+	for _, p := range r.progress {
+		out = append(out, p.Pod+"="+p.Status)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestSkipReason(t *testing.T) {
+	mirror := testNodePod("static", "")
+	mirror.Annotations = map[string]string{v1.MirrorPodAnnotationKey: "hash"}
+	finished := testNodePod("job", "Job")
+	finished.Status.Phase = v1.PodSucceeded
+	tests := []struct {
+		name string
+		pod  *v1.Pod
+		want string
+	}{
+		{name: "mirror", pod: mirror, want: "mirror pod"},
+		{name: "daemonset", pod: testNodePod("agent", "DaemonSet"), want: "daemonset pod"},
+		{name: "finished", pod: finished, want: "finished pod"},
+		{name: "other", pod: testNodePod("web", "ReplicaSet"), want: "not evicted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skipReason(tt.pod); got != tt.want {
+				t.Fatalf("skipReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrainNodeDryRun(t *testing.T) {
+	client_set := testDrainClient()
+	m := &K8{}
+	m.SetDryRun(true)
+	recorder := &drainRecorder{}
+	err := m.drainNode(client_set, "node-1", &DrainOptions{GracePeriodSeconds: -1, Timeout: 10 * time.Second, Progress: recorder.record})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"agent=skipped", "static=skipped", "web=dry-run"}
+	if got := recorder.statuses(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("progress = %v, want %v", got, want)
+	}
+	if _, err := client_set.CoreV1().Pods("default").Get(context.TODO(), "web", metav1.GetOptions{}); err != nil {
+		t.Fatalf("a pod was evicted in dry run mode: %v", err)
+	}
+	for _, a := range client_set.Actions() {
+		if a.GetVerb() == "delete" || a.GetSubresource() == "eviction" {
+			t.Fatalf("dry run made a %s %s call", a.GetVerb(), a.GetResource().Resource)
+		}
+	}
+}
+
+func TestDrainNode(t *testing.T) {
+	client_set := testDrainClient()
+	m := &K8{}
+	recorder := &drainRecorder{}
+	err := m.drainNode(client_set, "node-1", &DrainOptions{GracePeriodSeconds: -1, Timeout: 10 * time.Second, Progress: recorder.record})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"agent=skipped", "static=skipped", "web=evicted", "web=evicting"}
+	if got := recorder.statuses(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("progress = %v, want %v", got, want)
+	}
+	node, err := client_set.CoreV1().Nodes().Get(context.TODO(), "node-1", metav1.GetOptions{})
+	if err != nil || !node.Spec.Unschedulable {
+		t.Fatalf("the node was not cordoned: %v", err)
+	}
+	if _, err := client_set.CoreV1().Pods("default").Get(context.TODO(), "web", metav1.GetOptions{}); err == nil {
+		t.Fatal("the replicaset pod was not evicted")
+	}
+	if _, err := client_set.CoreV1().Pods("default").Get(context.TODO(), "agent", metav1.GetOptions{}); err != nil {
+		t.Fatalf("the daemonset pod was removed: %v", err)
+	}
+}
+
+func TestDrainNodeUnmanagedPod(t *testing.T) {
+	client_set := testDrainClient()
+	client_set.Tracker().Add(testNodePod("bare", ""))
+	m := &K8{}
+	err := m.drainNode(client_set, "node-1", &DrainOptions{GracePeriodSeconds: -1, Timeout: 10 * time.Second})
+	if err == nil {
+		t.Fatal("a pod without a controller was evicted without force")
+	}
+}
+
+func TestSetUnschedulable(t *testing.T) {
+	client_set := testDrainClient()
+	m := &K8{}
+	for _, cordon := range []bool{true, true, false} {
+		if err := m.setUnschedulable(client_set, "node-1", cordon); err != nil {
+			t.Fatal(err)
+		}
+		node, err := client_set.CoreV1().Nodes().Get(context.TODO(), "node-1", metav1.GetOptions{})
+		if err != nil || node.Spec.Unschedulable != cordon {
+			t.Fatalf("unschedulable = %t, want %t", node.Spec.Unschedulable, cordon)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package go_k8_helm
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultPageSize is the number of items fetched per request when no page size is given
+const DefaultPageSize int64 = 500
+
+// errStopStream is used to stop paging when a stream is stopped
+var errStopStream = errors.New("stream stopped")
+
+// paginate lists items in chunks using Limit and Continue and calls fn for each item
+// If the continue token expires the list is restarted and the items already seen are skipped
+// page_size: the number of items per request, 0 uses DefaultPageSize
+// opts: the list options
+// list: lists one chunk and returns the items and the continue token
+// fn: called for each item, return an error to stop
+// return: error
+func paginate[T any](page_size int64, opts []ListOption, list func(opts metav1.ListOptions) ([]T, string, error), fn func(item *T) error) error {
+	list_options, err := newListOptions(opts)
+	if err != nil {
+		return err
+	}
+	if page_size <= 0 {
+		page_size = DefaultPageSize
+	}
+	list_options.Limit = page_size
+
+	seen := map[types.UID]bool{}
+	restarted := false
+	for {
+		items, cont, err := list(list_options)
+		if apierrors.IsResourceExpired(err) && list_options.Continue != "" {
+			//*************************************
+			//Token expired so start the list again
+			//*************************************
+			log.Printf("Info: Continue token expired, restarting the list\n")
+			list_options.Continue = ""
+			restarted = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for i := range items {
+			item := &items[i]
+			if obj, ok := any(item).(metav1.Object); ok {
+				uid := obj.GetUID()
+				if restarted && seen[uid] {
+					continue
+				}
+				seen[uid] = true
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+
+		if cont == "" {
+			return nil
+		}
+		list_options.Continue = cont
+	}
+}
+
+// ForEach lists any kind in pages and calls fn for each object as it arrives
+// kind: a kind, resource or short name e.g. deploy, ingress or certificates.cert-manager.io
+// ns: namespace, empty for all namespaces
+// page_size: the number of objects per request, 0 uses DefaultPageSize
+// fn: called for each object, return an error to stop
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: error
+func (m *K8) ForEach(kind string, ns string, page_size int64, fn func(obj *unstructured.Unstructured) error, opts ...ListOption) error {
+	mapping, err := m.ResolveKind(kind)
+	if err != nil {
+		return err
+	}
+	dr, err := m.mappingResource(mapping, ns)
+	if err != nil {
+		return err
+	}
+	return paginate(page_size, opts, func(o metav1.ListOptions) ([]unstructured.Unstructured, string, error) {
+		list, err := dr.List(m.ctx, o)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.GetContinue(), nil
+	}, fn)
+}
+
+// Stream lists any kind in pages and sends each object on a channel as it arrives
+// The object channel is closed when the list is done
+// The error channel gets at most one error
+// Call stop to end the stream early
+// kind: a kind, resource or short name e.g. deploy, ingress or certificates.cert-manager.io
+// ns: namespace, empty for all namespaces
+// page_size: the number of objects per request, 0 uses DefaultPageSize
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: object channel, error channel, stop function
+func (m *K8) Stream(kind string, ns string, page_size int64, opts ...ListOption) (<-chan *unstructured.Unstructured, <-chan error, func()) {
+	items := make(chan *unstructured.Unstructured)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(done) })
+	}
+
+	go func() {
+		defer close(items)
+		err := m.ForEach(kind, ns, page_size, func(obj *unstructured.Unstructured) error {
+			select {
+			case items <- obj:
+				return nil
+			case <-done:
+				return errStopStream
+			}
+		}, opts...)
+		if err != nil && err != errStopStream {
+			errs <- err
+		}
+		close(errs)
+	}()
+	return items, errs, stop
+}
+
+// ForEachPod lists pods in pages and calls fn for each pod as it arrives
+// ns: namespace, empty for all namespaces
+// page_size: the number of pods per request, 0 uses DefaultPageSize
+// fn: called for each pod, return an error to stop
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: error
+func (m *K8) ForEachPod(ns string, page_size int64, fn func(pod *v1.Pod) error, opts ...ListOption) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	return paginate(page_size, opts, func(o metav1.ListOptions) ([]v1.Pod, string, error) {
+		list, err := client_set.CoreV1().Pods(ns).List(context.TODO(), o)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	}, fn)
+}
+
+// ForEachSecret lists secrets in pages and calls fn for each secret as it arrives
+// ns: namespace, empty for all namespaces
+// page_size: the number of secrets per request, 0 uses DefaultPageSize
+// fn: called for each secret, return an error to stop
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: error
+func (m *K8) ForEachSecret(ns string, page_size int64, fn func(secret *v1.Secret) error, opts ...ListOption) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	return paginate(page_size, opts, func(o metav1.ListOptions) ([]v1.Secret, string, error) {
+		list, err := client_set.CoreV1().Secrets(ns).List(context.TODO(), o)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	}, fn)
+}
+
+// ForEachService lists services in pages and calls fn for each service as it arrives
+// ns: namespace, empty for all namespaces
+// page_size: the number of services per request, 0 uses DefaultPageSize
+// fn: called for each service, return an error to stop
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: error
+func (m *K8) ForEachService(ns string, page_size int64, fn func(service *v1.Service) error, opts ...ListOption) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	return paginate(page_size, opts, func(o metav1.ListOptions) ([]v1.Service, string, error) {
+		list, err := client_set.CoreV1().Services(ns).List(context.TODO(), o)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	}, fn)
+}
+
+// ForEachDeployment lists deployments in pages and calls fn for each deployment as it arrives
+// ns: namespace, empty for all namespaces
+// page_size: the number of deployments per request, 0 uses DefaultPageSize
+// fn: called for each deployment, return an error to stop
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: error
+func (m *K8) ForEachDeployment(ns string, page_size int64, fn func(deployment *appsv1.Deployment) error, opts ...ListOption) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	return paginate(page_size, opts, func(o metav1.ListOptions) ([]appsv1.Deployment, string, error) {
+		list, err := client_set.AppsV1().Deployments(ns).List(context.TODO(), o)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	}, fn)
+}
+
+// ForEachStatefulSet lists statefulsets in pages and calls fn for each statefulset as it arrives
+// ns: namespace, empty for all namespaces
+// page_size: the number of statefulsets per request, 0 uses DefaultPageSize
+// fn: called for each statefulset, return an error to stop
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: error
+func (m *K8) ForEachStatefulSet(ns string, page_size int64, fn func(stateful *appsv1.StatefulSet) error, opts ...ListOption) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	return paginate(page_size, opts, func(o metav1.ListOptions) ([]appsv1.StatefulSet, string, error) {
+		list, err := client_set.AppsV1().StatefulSets(ns).List(context.TODO(), o)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	}, fn)
+}
+
+// ForEachDemonSet lists demonsets in pages and calls fn for each demonset as it arrives
+// ns: namespace, empty for all namespaces
+// page_size: the number of demonsets per request, 0 uses DefaultPageSize
+// fn: called for each demonset, return an error to stop
+// opts: list options e.g. OptionListLabelSelector("app=web")
+// return: error
+func (m *K8) ForEachDemonSet(ns string, page_size int64, fn func(demonset *appsv1.DaemonSet) error, opts ...ListOption) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	return paginate(page_size, opts, func(o metav1.ListOptions) ([]appsv1.DaemonSet, string, error) {
+		list, err := client_set.AppsV1().DaemonSets(ns).List(context.TODO(), o)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	}, fn)
+}
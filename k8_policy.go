@@ -0,0 +1,435 @@
+package go_k8_helm
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/postrender"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PolicyAction is what happens when a policy rule is violated
+type PolicyAction int
+
+const (
+	// PolicyWarn logs the violation and carries on
+	PolicyWarn PolicyAction = iota
+	// PolicyBlock stops the apply or helm install
+	PolicyBlock
+)
+
+// String returns the string representation of the policy action
+func (a PolicyAction) String() string {
+	if a == PolicyBlock {
+		return "block"
+	}
+	return "warn"
+}
+
+// MarshalText returns the action as text for json
+func (a PolicyAction) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// PolicyFunc checks an object and returns a message for each violation
+type PolicyFunc func(obj *unstructured.Unstructured) []string
+
+// PolicyRule is a named check run against each object before it reaches the cluster
+type PolicyRule struct {
+	Name   string
+	Action PolicyAction
+	Check  PolicyFunc
+}
+
+// NewPolicyRule creates a policy rule
+// name: name of the rule used in the report
+// action: warn or block
+// check: the check to run against each object
+func NewPolicyRule(name string, action PolicyAction, check PolicyFunc) PolicyRule {
+	return PolicyRule{Name: name, Action: action, Check: check}
+}
+
+// PolicyViolation is a single rule violation
+type PolicyViolation struct {
+	Rule      string       `json:"rule" yaml:"rule"`
+	Action    PolicyAction `json:"action" yaml:"action"`
+	Kind      string       `json:"kind" yaml:"kind"`
+	Namespace string       `json:"namespace" yaml:"namespace"`
+	Name      string       `json:"name" yaml:"name"`
+	Message   string       `json:"message" yaml:"message"`
+}
+
+// String returns the string representation of the violation
+func (v PolicyViolation) String() string {
+	return fmt.Sprintf("%s(%s) Kind(%s) Namespace(%s) Name(%s) %s", v.Rule, v.Action, v.Kind, v.Namespace, v.Name, v.Message)
+}
+
+// PolicyReport is the result of running the policy rules
+type PolicyReport struct {
+	Violations []PolicyViolation `json:"violations" yaml:"violations"`
+}
+
+// Blocked returns true if any violation has the block action
+func (r *PolicyReport) Blocked() bool {
+	for _, v := range r.Violations {
+		if v.Action == PolicyBlock {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyReporter is called with the report each time the policy rules are run
+// for ApplyYaml, ProcessK8File, ProcessK8FileTransaction and the rendered helm charts
+type PolicyReporter func(report *PolicyReport)
+
+// PolicyError is returned when a policy rule blocks an apply or helm install
+type PolicyError struct {
+	Report *PolicyReport
+}
+
+// Error returns the blocking violations
+func (e *PolicyError) Error() string {
+	var msgs []string
+	for _, v := range e.Report.Violations {
+		if v.Action == PolicyBlock {
+			msgs = append(msgs, v.String())
+		}
+	}
+	return "blocked by policy: " + strings.Join(msgs, "; ")
+}
+
+// Policies returns the policy rules
+func (m *K8) Policies() []PolicyRule {
+	return m.policies
+}
+
+// SetPolicies sets the policy rules
+// The rules run on ApplyYaml, ProcessK8File and on the rendered helm charts
+func (m *K8) SetPolicies(rules ...PolicyRule) {
+	m.policies = rules
+}
+
+// AddPolicies adds policy rules
+func (m *K8) AddPolicies(rules ...PolicyRule) {
+	m.policies = append(m.policies, rules...)
+}
+
+// OptionK8Policies is the option for the policy rules
+func OptionK8Policies(rules ...PolicyRule) K8Option {
+	return func(h *K8) {
+		h.policies = rules
+	}
+}
+
+// PolicyReporter returns the policy reporter
+func (m *K8) PolicyReporter() PolicyReporter {
+	return m.policy_reporter
+}
+
+// SetPolicyReporter sets the function called with the report each time the policy rules are run
+// Use it to get the warnings, a blocking report is also returned as a PolicyError
+func (m *K8) SetPolicyReporter(reporter PolicyReporter) {
+	m.policy_reporter = reporter
+}
+
+// OptionK8PolicyReporter is the option for the policy reporter
+func OptionK8PolicyReporter(reporter PolicyReporter) K8Option {
+	return func(h *K8) {
+		h.policy_reporter = reporter
+	}
+}
+
+// CheckPolicies runs the policy rules against a k8 file
+// with multiple definitions separated with ---
+// The namespace is set with the namespace policy and the mutation is applied before the rules are run
+// file_data: file data
+// ns: namespace
+// return: *PolicyReport, error
+func (m *K8) CheckPolicies(file_data []byte, ns string) (*PolicyReport, error) {
+	return m.checkPolicies(file_data, ns, m.namespace_policy)
+}
+
+// checkPolicies runs the policy rules against a k8 file
+// Objects are treated as namespaced when there is no cluster to look up their scope
+// file_data: file data
+// ns: namespace
+// policy: the namespace policy used to set the namespace of each object
+// return: *PolicyReport, error
+func (m *K8) checkPolicies(file_data []byte, ns string, policy NamespaceMode) (*PolicyReport, error) {
+	report := &PolicyReport{}
+	for _, o := range m.splitK8File(file_data, ns, false) {
+		if isEmptyYaml(o) {
+			continue
+		}
+		obj, gvk, err := decodeYaml(o)
+		if err != nil {
+			return nil, err
+		}
+		namespaced := true
+		if m.config != nil {
+			if mapping, err := m.restMapping(gvk); err == nil {
+				namespaced = mapping.Scope.Name() == meta.RESTScopeNameNamespace
+			}
+		}
+		if err := setObjectNamespace(obj, namespaced, ns, policy); err != nil {
+			return nil, err
+		}
+		m.mutateObject(obj)
+		m.checkObjectPolicies(report, obj)
+	}
+	return report, nil
+}
+
+// checkObjectPolicies runs the policy rules against an object and adds the violations to the report
+// report: the report to update
+// obj: the object to check
+func (m *K8) checkObjectPolicies(report *PolicyReport, obj *unstructured.Unstructured) {
+	for _, rule := range m.policies {
+		for _, msg := range rule.Check(obj) {
+			report.Violations = append(report.Violations, PolicyViolation{
+				Rule:      rule.Name,
+				Action:    rule.Action,
+				Kind:      obj.GetKind(),
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+				Message:   msg,
+			})
+		}
+	}
+}
+
+// checkPartsPolicies runs the policy rules against the yaml parts
+// reports and logs the warnings and returns a PolicyError if any rule blocks
+// parts: the yaml parts
+// ns: namespace
+// return: error
+func (m *K8) checkPartsPolicies(parts []string, ns string) error {
+	if len(m.policies) == 0 {
+		return nil
+	}
+	report, err := m.CheckPolicies([]byte(strings.Join(parts, "---\n")), ns)
+	if err != nil {
+		return err
+	}
+	return m.enforcePolicies(report)
+}
+
+// enforcePolicies passes the report to the policy reporter, logs the warnings
+// and returns a PolicyError if any rule blocks
+// report: the policy report
+// return: error
+func (m *K8) enforcePolicies(report *PolicyReport) error {
+	if m.policy_reporter != nil {
+		m.policy_reporter(report)
+	}
+	for _, v := range report.Violations {
+		if v.Action == PolicyWarn {
+			log.Printf("Warning: Policy %s\n", v.String())
+		}
+	}
+	if report.Blocked() {
+		return &PolicyError{Report: report}
+	}
+	return nil
+}
+
+// isEmptyYaml returns true if the yaml only has comments and white space
+func isEmptyYaml(yaml string) bool {
+	for _, line := range strings.Split(yaml, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// helmPostRenderer applies the mutation and runs the policy rules against the rendered helm manifest
+type helmPostRenderer struct {
+	k8        *K8
+	namespace string
+}
+
+// postRenderer returns the helm post renderer
+// returns nil if there is no mutation and no policy rules
+// namespace: the namespace of the release
+func (m *K8) postRenderer(namespace string) postrender.PostRenderer {
+	if len(m.policies) == 0 && m.mutation == nil {
+		return nil
+	}
+	return &helmPostRenderer{k8: m, namespace: namespace}
+}
+
+// Run mutates and checks the rendered manifest
+// returns an error if a policy rule blocks
+func (p *helmPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	if p.k8.mutation != nil {
+		data, err := p.k8.MutateK8File(renderedManifests.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		renderedManifests = bytes.NewBuffer(data)
+	}
+	if len(p.k8.policies) > 0 {
+		// helm only sets the release namespace on objects that do not declare one
+		report, err := p.k8.checkPolicies(renderedManifests.Bytes(), p.namespace, NamespaceFillEmpty)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.k8.enforcePolicies(report); err != nil {
+			return nil, err
+		}
+	}
+	return renderedManifests, nil
+}
+
+// BuiltinPolicies returns the built-in rule set
+// action: warn or block for all the rules
+// required_labels: labels that every object must have, can be empty
+func BuiltinPolicies(action PolicyAction, required_labels ...string) []PolicyRule {
+	rules := []PolicyRule{
+		PolicyNoPrivileged(action),
+		PolicyNoLatestTag(action),
+		PolicyRequireLimits(action),
+		PolicyNoHostPath(action),
+	}
+	if len(required_labels) > 0 {
+		rules = append(rules, PolicyRequiredLabels(action, required_labels...))
+	}
+	return rules
+}
+
+// PolicyNoPrivileged blocks privileged containers
+func PolicyNoPrivileged(action PolicyAction) PolicyRule {
+	return NewPolicyRule("no-privileged", action, func(obj *unstructured.Unstructured) []string {
+		var msgs []string
+		for _, c := range podContainers(obj) {
+			privileged, _, _ := unstructured.NestedBool(c, "securityContext", "privileged")
+			if privileged {
+				msgs = append(msgs, fmt.Sprintf("container %s is privileged", c["name"]))
+			}
+		}
+		return msgs
+	})
+}
+
+// PolicyNoLatestTag blocks images using the latest tag or no tag
+func PolicyNoLatestTag(action PolicyAction) PolicyRule {
+	return NewPolicyRule("no-latest-tag", action, func(obj *unstructured.Unstructured) []string {
+		var msgs []string
+		for _, c := range podContainers(obj) {
+			image, _, _ := unstructured.NestedString(c, "image")
+			// an empty image is left to the schema validation
+			if image == "" || strings.Contains(image, "@") {
+				continue
+			}
+			if strings.HasSuffix(image, ":latest") || !strings.Contains(image[strings.LastIndex(image, "/")+1:], ":") {
+				msgs = append(msgs, fmt.Sprintf("container %s uses the latest tag (%s)", c["name"], image))
+			}
+		}
+		return msgs
+	})
+}
+
+// PolicyRequireLimits requires cpu and memory limits on all containers
+func PolicyRequireLimits(action PolicyAction) PolicyRule {
+	return NewPolicyRule("require-limits", action, func(obj *unstructured.Unstructured) []string {
+		var msgs []string
+		for _, c := range podContainers(obj) {
+			limits, _, _ := unstructured.NestedMap(c, "resources", "limits")
+			for _, r := range []string{"cpu", "memory"} {
+				if _, ok := limits[r]; !ok {
+					msgs = append(msgs, fmt.Sprintf("container %s has no %s limit", c["name"], r))
+				}
+			}
+		}
+		return msgs
+	})
+}
+
+// PolicyNoHostPath blocks hostPath volumes
+func PolicyNoHostPath(action PolicyAction) PolicyRule {
+	return NewPolicyRule("no-host-path", action, func(obj *unstructured.Unstructured) []string {
+		spec, _ := podSpec(obj)
+		if spec == nil {
+			return nil
+		}
+		var msgs []string
+		volumes, _, _ := unstructured.NestedSlice(spec, "volumes")
+		for _, v := range volumes {
+			volume, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, ok := volume["hostPath"]; ok {
+				msgs = append(msgs, fmt.Sprintf("volume %s uses hostPath", volume["name"]))
+			}
+		}
+		return msgs
+	})
+}
+
+// PolicyRequiredLabels requires labels on every object
+// labels: the label keys that must be set
+func PolicyRequiredLabels(action PolicyAction, labels ...string) PolicyRule {
+	return NewPolicyRule("required-labels", action, func(obj *unstructured.Unstructured) []string {
+		var msgs []string
+		current := obj.GetLabels()
+		for _, l := range labels {
+			if current[l] == "" {
+				msgs = append(msgs, fmt.Sprintf("missing label %s", l))
+			}
+		}
+		return msgs
+	})
+}
+
+// podSpec finds the pod spec of a workload object
+// The pod spec is not copied so changes are made to the object
+// obj: the object
+// return: the pod spec and the path to it, nil if the kind has no pod spec
+func podSpec(obj *unstructured.Unstructured) (map[string]interface{}, []string) {
+	var path []string
+	switch obj.GetKind() {
+	case "Pod":
+		path = []string{"spec"}
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "ReplicationController", "Job":
+		path = []string{"spec", "template", "spec"}
+	case "CronJob":
+		path = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		return nil, nil
+	}
+	field, found, _ := unstructured.NestedFieldNoCopy(obj.Object, path...)
+	spec, ok := field.(map[string]interface{})
+	if !found || !ok {
+		return nil, nil
+	}
+	return spec, path
+}
+
+// podContainers returns the init containers and containers of a workload object
+// Ephemeral containers are added through the ephemeralcontainers subresource and are never part of a manifest
+// The containers are not copied so changes are made to the object
+// obj: the object
+// return: the containers
+func podContainers(obj *unstructured.Unstructured) []map[string]interface{} {
+	spec, _ := podSpec(obj)
+	if spec == nil {
+		return nil
+	}
+	var containers []map[string]interface{}
+	for _, field := range []string{"initContainers", "containers"} {
+		list, _ := spec[field].([]interface{})
+		for _, c := range list {
+			if container, ok := c.(map[string]interface{}); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+	return containers
+}
@@ -0,0 +1,192 @@
+package go_k8_helm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// testObject decodes a yaml manifest for a test
+func testObject(t *testing.T, yaml string) *unstructured.Unstructured {
+	t.Helper()
+	obj, _, err := decodeYaml(yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+const testPolicyDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox:1.36
+        resources:
+          limits: {cpu: 100m, memory: 64Mi}
+      containers:
+      - name: web
+        image: nginx
+        securityContext:
+          privileged: true
+      ephemeralContainers:
+      - name: debug
+        image: busybox:latest
+        securityContext:
+          privileged: true
+      volumes:
+      - name: data
+        hostPath:
+          path: /data
+      - name: cache
+        emptyDir: {}
+`
+
+func TestPodContainers(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{name: "deployment", yaml: testPolicyDeployment, want: []string{"init", "web"}},
+		{name: "pod", yaml: "apiVersion: v1\nkind: Pod\nmetadata: {name: p}\nspec:\n  containers:\n  - name: a\n  - name: b\n", want: []string{"a", "b"}},
+		{name: "cronjob", yaml: "apiVersion: batch/v1\nkind: CronJob\nmetadata: {name: c}\nspec:\n  jobTemplate:\n    spec:\n      template:\n        spec:\n          containers:\n          - name: job\n", want: []string{"job"}},
+		{name: "no pod spec", yaml: "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: c}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, c := range podContainers(testObject(t, tt.yaml)) {
+				names = append(names, c["name"].(string))
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("podContainers() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuiltinPolicies(t *testing.T) {
+	obj := testObject(t, testPolicyDeployment)
+	tests := []struct {
+		rule PolicyRule
+		want []string
+	}{
+		{rule: PolicyNoPrivileged(PolicyWarn), want: []string{"container web is privileged"}},
+		{rule: PolicyNoLatestTag(PolicyWarn), want: []string{"container web uses the latest tag (nginx)"}},
+		{rule: PolicyRequireLimits(PolicyWarn), want: []string{"container web has no cpu limit", "container web has no memory limit"}},
+		{rule: PolicyNoHostPath(PolicyWarn), want: []string{"volume data uses hostPath"}},
+		{rule: PolicyRequiredLabels(PolicyWarn, "app", "team"), want: []string{"missing label team"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule.Name, func(t *testing.T) {
+			if got := tt.rule.Check(obj); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("%s = %v, want %v", tt.rule.Name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyNoLatestTag(t *testing.T) {
+	tests := []struct {
+		image string
+		want  bool
+	}{
+		{image: "nginx", want: true},
+		{image: "nginx:latest", want: true},
+		{image: "nginx:1.23"},
+		{image: "registry:5000/nginx", want: true},
+		{image: "registry:5000/nginx:1.23"},
+		{image: "nginx@sha256:abc"},
+		{image: ""},
+	}
+	rule := PolicyNoLatestTag(PolicyBlock)
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			obj := testObject(t, "apiVersion: v1\nkind: Pod\nmetadata: {name: p}\nspec:\n  containers:\n  - name: a\n    image: "+tt.image+"\n")
+			if got := len(rule.Check(obj)) > 0; got != tt.want {
+				t.Fatalf("no-latest-tag(%s) = %t, want %t", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforcePolicies(t *testing.T) {
+	var reports []*PolicyReport
+	m := &K8{}
+	m.SetPolicyReporter(func(report *PolicyReport) {
+		reports = append(reports, report)
+	})
+
+	warn := &PolicyReport{Violations: []PolicyViolation{{Rule: "a", Action: PolicyWarn}}}
+	if err := m.enforcePolicies(warn); err != nil {
+		t.Fatalf("a warning blocked: %v", err)
+	}
+	block := &PolicyReport{Violations: []PolicyViolation{{Rule: "a", Action: PolicyWarn}, {Rule: "b", Action: PolicyBlock}}}
+	err := m.enforcePolicies(block)
+	var policy_err *PolicyError
+	if !errors.As(err, &policy_err) || policy_err.Report != block {
+		t.Fatalf("a block violation returned %v", err)
+	}
+	if len(reports) != 2 || reports[0] != warn || reports[1] != block {
+		t.Fatalf("the reporter was called with %v", reports)
+	}
+}
+
+func TestCheckPoliciesNamespace(t *testing.T) {
+	yaml := "apiVersion: v1\nkind: Pod\nmetadata: {name: p, namespace: other}\nspec:\n  containers:\n  - name: a\n    image: nginx\n"
+	tests := []struct {
+		name    string
+		policy  NamespaceMode
+		want    string
+		wantErr bool
+	}{
+		{name: "override", policy: NamespaceOverride, want: "app"},
+		{name: "fill empty", policy: NamespaceFillEmpty, want: "other"},
+		{name: "reject mismatch", policy: NamespaceRejectMismatch, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &K8{}
+			m.SetNamespacePolicy(tt.policy)
+			m.SetPolicies(PolicyNoLatestTag(PolicyWarn))
+			report, err := m.CheckPolicies([]byte(yaml), "app")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckPolicies() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(report.Violations) != 1 || report.Violations[0].Namespace != tt.want {
+				t.Fatalf("CheckPolicies() = %+v, want namespace %s", report.Violations, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPartsPoliciesReport(t *testing.T) {
+	var got *PolicyReport
+	m := &K8{}
+	m.Update(OptionK8Policies(PolicyNoLatestTag(PolicyWarn)), OptionK8PolicyReporter(func(report *PolicyReport) {
+		got = report
+	}))
+	parts := []string{
+		"apiVersion: v1\nkind: Pod\nmetadata: {name: a}\nspec:\n  containers:\n  - name: a\n    image: nginx\n",
+		"apiVersion: v1\nkind: Pod\nmetadata: {name: b}\nspec:\n  containers:\n  - name: b\n    image: nginx:1.23\n",
+	}
+	if err := m.checkPartsPolicies(parts, "app"); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || len(got.Violations) != 1 || got.Violations[0].Name != "a" || got.Violations[0].Action != PolicyWarn {
+		t.Fatalf("the warning was not reported: %+v", got)
+	}
+}
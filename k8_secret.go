@@ -0,0 +1,318 @@
+package go_k8_helm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// SecretOption adds keys or settings to a secret
+type SecretOption func(*v1.Secret) error
+
+// OptionSecretLiteral is the option to add a key with a value
+// key: the key
+// value: the value
+func OptionSecretLiteral(key string, value string) SecretOption {
+	return func(s *v1.Secret) error {
+		return addSecretKey(s, key, []byte(value))
+	}
+}
+
+// OptionSecretFile is the option to add a file using the file name as the key
+// path: the file path
+func OptionSecretFile(path string) SecretOption {
+	return OptionSecretFileKey(filepath.Base(path), path)
+}
+
+// OptionSecretFileKey is the option to add a file with a key
+// key: the key
+// path: the file path
+func OptionSecretFileKey(key string, path string) SecretOption {
+	return func(s *v1.Secret) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return addSecretKey(s, key, data)
+	}
+}
+
+// OptionSecretEnvFile is the option to add the KEY=value lines of a .env file
+// path: the .env file path
+func OptionSecretEnvFile(path string) SecretOption {
+	return func(s *v1.Secret) error {
+		return readEnvFile(path, func(key string, value []byte) error {
+			return addSecretKey(s, key, value)
+		})
+	}
+}
+
+// OptionSecretImmutable is the option to make the secret immutable
+// An immutable secret can only be changed by UpsertSecret with replace set
+func OptionSecretImmutable(immutable bool) SecretOption {
+	return func(s *v1.Secret) error {
+		s.Immutable = &immutable
+		return nil
+	}
+}
+
+// OptionSecretLabels is the option to add labels to the secret
+func OptionSecretLabels(labels map[string]string) SecretOption {
+	return func(s *v1.Secret) error {
+		for k, v := range labels {
+			metav1.SetMetaDataLabel(&s.ObjectMeta, k, v)
+		}
+		return nil
+	}
+}
+
+// addSecretKey adds a key to the secret data
+func addSecretKey(s *v1.Secret, key string, value []byte) error {
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		return fmt.Errorf("invalid key %s: %s", key, strings.Join(errs, ", "))
+	}
+	if _, found := s.Data[key]; found {
+		return fmt.Errorf("duplicate key %s", key)
+	}
+	if s.Data == nil {
+		s.Data = map[string][]byte{}
+	}
+	s.Data[key] = value
+	return nil
+}
+
+// newSecret builds a secret of a type with the data and applies the options
+func newSecret(ns string, name string, secret_type v1.SecretType, data map[string][]byte, opts []SecretOption) (*v1.Secret, error) {
+	s := &v1.Secret{
+		TypeMeta:   metav1.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Type:       secret_type,
+		Data:       data,
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NewGenericSecret builds an Opaque secret from literals, files and .env files
+// e.g. NewGenericSecret("default", "db", OptionSecretLiteral("password", "pass"), OptionSecretEnvFile(".env"))
+// ns: namespace
+// name: name of the secret
+// opts: the keys and settings
+// return: *v1.Secret, error
+func NewGenericSecret(ns string, name string, opts ...SecretOption) (*v1.Secret, error) {
+	return newSecret(ns, name, v1.SecretTypeOpaque, nil, opts)
+}
+
+// NewTLSSecret builds a kubernetes.io/tls secret
+// The certificate and key must be PEM and the key must match the certificate
+// ns: namespace
+// name: name of the secret
+// cert_pem: the PEM certificate chain
+// key_pem: the PEM private key
+// opts: extra settings e.g. OptionSecretImmutable(true)
+// return: *v1.Secret, error
+func NewTLSSecret(ns string, name string, cert_pem []byte, key_pem []byte, opts ...SecretOption) (*v1.Secret, error) {
+	if block, _ := pem.Decode(cert_pem); block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("certificate is not a PEM certificate")
+	}
+	if block, _ := pem.Decode(key_pem); block == nil || !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return nil, errors.New("key is not a PEM private key")
+	}
+	pair, err := tls.X509KeyPair(cert_pem, key_pem)
+	if err != nil {
+		return nil, fmt.Errorf("certificate and key do not match: %w", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(cert.NotAfter) {
+		log.Printf("Warning: Certificate for %s expired on %s\n", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+	}
+	return newSecret(ns, name, v1.SecretTypeTLS, map[string][]byte{
+		v1.TLSCertKey:       cert_pem,
+		v1.TLSPrivateKeyKey: key_pem,
+	}, opts)
+}
+
+// NewTLSSecretFromFiles builds a kubernetes.io/tls secret from PEM files
+// ns: namespace
+// name: name of the secret
+// cert_file: path to the PEM certificate chain
+// key_file: path to the PEM private key
+// opts: extra settings e.g. OptionSecretImmutable(true)
+// return: *v1.Secret, error
+func NewTLSSecretFromFiles(ns string, name string, cert_file string, key_file string, opts ...SecretOption) (*v1.Secret, error) {
+	cert_pem, err := os.ReadFile(cert_file)
+	if err != nil {
+		return nil, err
+	}
+	key_pem, err := os.ReadFile(key_file)
+	if err != nil {
+		return nil, err
+	}
+	return NewTLSSecret(ns, name, cert_pem, key_pem, opts...)
+}
+
+// NewDockerConfigSecret builds a kubernetes.io/dockerconfigjson secret for pulling images
+// ns: namespace
+// name: name of the secret
+// registry: the registry server e.g. ghcr.io or https://index.docker.io/v1/
+// username: the registry user
+// password: the registry password or token
+// email: the email, can be empty
+// opts: extra settings e.g. OptionSecretImmutable(true)
+// return: *v1.Secret, error
+func NewDockerConfigSecret(ns string, name string, registry string, username string, password string, email string, opts ...SecretOption) (*v1.Secret, error) {
+	if registry == "" || username == "" || password == "" {
+		return nil, errors.New("registry, username and password are required")
+	}
+	auth := map[string]string{
+		"username": username,
+		"password": password,
+		"auth":     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+	}
+	if email != "" {
+		auth["email"] = email
+	}
+	config, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{registry: auth},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newSecret(ns, name, v1.SecretTypeDockerConfigJson, map[string][]byte{
+		v1.DockerConfigJsonKey: config,
+	}, opts)
+}
+
+// NewBasicAuthSecret builds a kubernetes.io/basic-auth secret
+// ns: namespace
+// name: name of the secret
+// username: the user
+// password: the password
+// opts: extra settings e.g. OptionSecretImmutable(true)
+// return: *v1.Secret, error
+func NewBasicAuthSecret(ns string, name string, username string, password string, opts ...SecretOption) (*v1.Secret, error) {
+	if username == "" && password == "" {
+		return nil, errors.New("username or password is required")
+	}
+	return newSecret(ns, name, v1.SecretTypeBasicAuth, map[string][]byte{
+		v1.BasicAuthUsernameKey: []byte(username),
+		v1.BasicAuthPasswordKey: []byte(password),
+	}, opts)
+}
+
+// NewSSHAuthSecret builds a kubernetes.io/ssh-auth secret
+// ns: namespace
+// name: name of the secret
+// private_key: the PEM or OpenSSH private key
+// opts: extra settings e.g. OptionSecretImmutable(true)
+// return: *v1.Secret, error
+func NewSSHAuthSecret(ns string, name string, private_key []byte, opts ...SecretOption) (*v1.Secret, error) {
+	if _, err := ssh.ParseRawPrivateKey(private_key); err != nil {
+		var missing *ssh.PassphraseMissingError
+		if !errors.As(err, &missing) {
+			return nil, fmt.Errorf("invalid ssh private key: %w", err)
+		}
+	}
+	return newSecret(ns, name, v1.SecretTypeSSHAuth, map[string][]byte{
+		v1.SSHAuthPrivateKey: private_key,
+	}, opts)
+}
+
+// CreateSecret creates a secret in a k8 cluster
+// secret: the secret e.g. from NewGenericSecret
+// return: error
+func (m *K8) CreateSecret(secret *v1.Secret) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	_, err = client_set.CoreV1().Secrets(secret.Namespace).Create(context.TODO(), secret, metav1.CreateOptions{
+		DryRun: m.dryRunOptions(),
+	})
+	return err
+}
+
+// UpsertSecret creates the secret or updates it when it exists
+// An existing immutable secret or a secret with a different type cannot be updated,
+// it is only deleted and created again when replace is set, pods that mount it may fail while it is missing
+// secret: the secret e.g. from NewTLSSecret
+// replace: if true replace a secret that cannot be updated, otherwise return an error
+// return: error
+func (m *K8) UpsertSecret(secret *v1.Secret, replace bool) error {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	client := client_set.CoreV1().Secrets(secret.Namespace)
+
+	err = m.CreateSecret(secret)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := client.Get(context.TODO(), secret.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return m.CreateSecret(secret)
+		}
+		if err != nil {
+			return err
+		}
+
+		//*****************************************
+		//Immutable secrets and types cannot change
+		//*****************************************
+		if (current.Immutable != nil && *current.Immutable) || current.Type != secret.Type {
+			if !replace {
+				return fmt.Errorf("secret %s in namespace %s is immutable or has type %s, it can only be replaced", secret.Name, secret.Namespace, current.Type)
+			}
+			log.Printf("Info: Replacing Secret(%s) in Namespace(%s)\n", secret.Name, secret.Namespace)
+			if m.dry_run {
+				return nil
+			}
+			err := deleteAndWait(secret.Name, client.Delete, deleteAndWaitGetter(client.Get), nil, []DeleteOption{OptionDeleteWait(time.Minute), OptionDeleteIgnoreNotFound(true)})
+			if err != nil {
+				return err
+			}
+			return m.CreateSecret(secret)
+		}
+
+		current.Data = secret.Data
+		current.StringData = secret.StringData
+		current.Immutable = secret.Immutable
+		for k, v := range secret.Labels {
+			metav1.SetMetaDataLabel(&current.ObjectMeta, k, v)
+		}
+		for k, v := range secret.Annotations {
+			metav1.SetMetaDataAnnotation(&current.ObjectMeta, k, v)
+		}
+		_, err = client.Update(context.TODO(), current, metav1.UpdateOptions{
+			DryRun: m.dryRunOptions(),
+		})
+		return err
+	})
+}
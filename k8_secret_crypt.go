@@ -0,0 +1,559 @@
+package go_k8_helm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	yaml_v3 "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// sopsVersion is the sops version written to the document metadata
+const sopsVersion = "3.7.3"
+
+// encryptedValue matches a SOPS style encrypted value
+var encryptedValue = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+// LoadAgeIdentityFile loads the age identities used to decrypt SOPS documents
+// path: path to the age identity file, the same format as age-keygen writes
+// return: error
+func (m *K8) LoadAgeIdentityFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return err
+	}
+	m.age_identities = append(m.age_identities, identities...)
+	return nil
+}
+
+// LoadSecretKeyFile loads the symmetric key used to decrypt secret values
+// The file holds a 32 byte key as raw bytes or base64
+// path: path to the key file
+// return: error
+func (m *K8) LoadSecretKeyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	key, err := parseSecretKey(data)
+	if err != nil {
+		return err
+	}
+	m.secret_key = key
+	return nil
+}
+
+// OptionK8AgeIdentities is the option for the age identities used to decrypt SOPS documents
+func OptionK8AgeIdentities(identities ...age.Identity) K8Option {
+	return func(h *K8) {
+		h.age_identities = identities
+	}
+}
+
+// OptionK8SecretKey is the option for the symmetric key used to decrypt secret values
+func OptionK8SecretKey(key []byte) K8Option {
+	return func(h *K8) {
+		h.secret_key = key
+	}
+}
+
+// GenerateSecretKey creates a new random symmetric key
+// return: the key as base64, ready to write to a key file
+func GenerateSecretKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// EncryptSecretYaml encrypts the data and stringData values of a Secret manifest
+// with a symmetric key so the manifest can be committed
+// A SOPS message authentication code over all the values is added to the sops metadata
+// ProcessK8File and ApplyYaml decrypt the values when the same key is loaded
+// yaml_data: the Secret manifest
+// key: the 32 byte symmetric key
+// return: the encrypted manifest, error
+func EncryptSecretYaml(yaml_data string, key []byte) (string, error) {
+	if len(key) != 32 {
+		return "", errors.New("secret key must be 32 bytes")
+	}
+	obj, err := encryptSecret(yaml_data, key)
+	if err != nil {
+		return "", err
+	}
+	return sealSopsDocument(obj, key, map[string]interface{}{})
+}
+
+// EncryptSecretYamlAge encrypts the data and stringData values of a Secret manifest
+// as a SOPS document for the age recipients so the manifest can be committed
+// ProcessK8File and ApplyYaml decrypt the document when a matching age identity is loaded
+// yaml_data: the Secret manifest
+// recipients: the age public keys e.g. age1...
+// return: the encrypted manifest, error
+func EncryptSecretYamlAge(yaml_data string, recipients ...string) (string, error) {
+	if len(recipients) == 0 {
+		return "", errors.New("at least one age recipient is required")
+	}
+	data_key := make([]byte, 32)
+	if _, err := rand.Read(data_key); err != nil {
+		return "", err
+	}
+	obj, err := encryptSecret(yaml_data, data_key)
+	if err != nil {
+		return "", err
+	}
+
+	//*********************************
+	//Encrypt the data key for each one
+	//*********************************
+	var age_meta []interface{}
+	for _, r := range recipients {
+		recipient, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		armored := armor.NewWriter(&buf)
+		w, err := age.Encrypt(armored, recipient)
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write(data_key); err != nil {
+			return "", err
+		}
+		if err := w.Close(); err != nil {
+			return "", err
+		}
+		if err := armored.Close(); err != nil {
+			return "", err
+		}
+		age_meta = append(age_meta, map[string]interface{}{"recipient": r, "enc": buf.String()})
+	}
+	return sealSopsDocument(obj, data_key, map[string]interface{}{
+		"age":             age_meta,
+		"encrypted_regex": "^(data|stringData)$",
+	})
+}
+
+// sealSopsDocument adds the sops metadata with the message authentication code to an encrypted object
+// obj: the encrypted object
+// key: the data key
+// meta: the sops metadata e.g. the age recipients
+// return: the document, error
+func sealSopsDocument(obj *unstructured.Unstructured, key []byte, meta map[string]interface{}) (string, error) {
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	mac, err := sopsMAC(string(data), key)
+	if err != nil {
+		return "", err
+	}
+
+	// the mac is bound to the last modified time
+	last_modified := time.Now().UTC().Format(time.RFC3339)
+	enc_mac, err := encryptValue(key, mac, last_modified)
+	if err != nil {
+		return "", err
+	}
+	meta["lastmodified"] = last_modified
+	meta["mac"] = enc_mac
+	meta["version"] = sopsVersion
+	obj.Object["sops"] = meta
+
+	data, err = yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// encryptSecret encrypts the data and stringData values of a Secret manifest
+// yaml_data: the Secret manifest
+// key: the 32 byte key
+// return: the encrypted object, error
+func encryptSecret(yaml_data string, key []byte) (*unstructured.Unstructured, error) {
+	obj, _, err := decodeYaml(yaml_data)
+	if err != nil {
+		return nil, err
+	}
+	if obj.GetKind() != "Secret" {
+		return nil, fmt.Errorf("kind(%s) is not a Secret", obj.GetKind())
+	}
+
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := obj.Object[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range values {
+			s, ok := v.(string)
+			if !ok || encryptedValue.MatchString(s) {
+				continue
+			}
+			enc, err := encryptValue(key, s, field+":"+k+":")
+			if err != nil {
+				return nil, err
+			}
+			values[k] = enc
+		}
+	}
+	return obj, nil
+}
+
+// isEncrypted returns true if the object is a SOPS document
+// or has encrypted values
+// obj: the object
+func isEncrypted(obj *unstructured.Unstructured) bool {
+	if _, ok := obj.Object["sops"]; ok {
+		return true
+	}
+	found := false
+	walkValues(obj.Object, nil, func(value string, path []string) (interface{}, error) {
+		if encryptedValue.MatchString(value) {
+			found = true
+		}
+		return value, nil
+	})
+	return found
+}
+
+// decryptObject decrypts a SOPS document or encrypted values in memory
+// The SOPS message authentication code is checked so values that were changed,
+// dropped or swapped in from another document are rejected
+// Encrypted values without sops metadata have no mac, only each value is authenticated
+// Nothing is written to disk or logged
+// obj: the object to decrypt
+// yaml_data: the manifest the object was decoded from, the mac follows its key order
+// return: error
+func (m *K8) decryptObject(obj *unstructured.Unstructured, yaml_data string) error {
+	if !isEncrypted(obj) {
+		return nil
+	}
+
+	key := m.secret_key
+	meta, is_sops := obj.Object["sops"].(map[string]interface{})
+	if is_sops {
+		if recipients, _, _ := unstructured.NestedSlice(meta, "age"); len(recipients) > 0 {
+			data_key, err := m.sopsDataKey(meta)
+			if err != nil {
+				return fmt.Errorf("kind(%s) name(%s) unable to decrypt: %w", obj.GetKind(), obj.GetName(), err)
+			}
+			key = data_key
+		}
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("kind(%s) name(%s) is encrypted but no decryption key is loaded", obj.GetKind(), obj.GetName())
+	}
+	if is_sops {
+		if err := verifySopsMAC(meta, yaml_data, key); err != nil {
+			return fmt.Errorf("kind(%s) name(%s) unable to decrypt: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		delete(obj.Object, "sops")
+	}
+
+	_, err := walkValues(obj.Object, nil, func(value string, path []string) (interface{}, error) {
+		if !encryptedValue.MatchString(value) {
+			return value, nil
+		}
+		return decryptValue(key, value, strings.Join(path, ":")+":")
+	})
+	if err != nil {
+		return fmt.Errorf("kind(%s) name(%s) unable to decrypt: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	return nil
+}
+
+// sopsDataKey decrypts the SOPS data key with the age identities
+// meta: the sops metadata of the document
+// return: the data key, error
+func (m *K8) sopsDataKey(meta map[string]interface{}) ([]byte, error) {
+	if len(m.age_identities) == 0 {
+		return nil, errors.New("no age identity is loaded")
+	}
+	recipients, _, _ := unstructured.NestedSlice(meta, "age")
+	for _, r := range recipients {
+		recipient, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		enc, _ := recipient["enc"].(string)
+		reader, err := age.Decrypt(armor.NewReader(strings.NewReader(enc)), m.age_identities...)
+		if err != nil {
+			continue
+		}
+		return io.ReadAll(reader)
+	}
+	return nil, errors.New("none of the age identities match the document recipients")
+}
+
+// verifySopsMAC checks the message authentication code of a SOPS document
+// meta: the sops metadata of the document
+// yaml_data: the document
+// key: the data key
+// return: error
+func verifySopsMAC(meta map[string]interface{}, yaml_data string, key []byte) error {
+	enc_mac, _ := meta["mac"].(string)
+	if !encryptedValue.MatchString(enc_mac) {
+		return errors.New("the document has no message authentication code")
+	}
+	last_modified, _ := meta["lastmodified"].(string)
+	if t, err := time.Parse(time.RFC3339, last_modified); err == nil {
+		last_modified = t.Format(time.RFC3339)
+	}
+	mac, _, err := decryptBytes(key, enc_mac, last_modified)
+	if err != nil {
+		return errors.New("unable to decrypt the message authentication code, wrong key or the metadata was changed")
+	}
+	computed, err := sopsMAC(yaml_data, key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, []byte(computed)) {
+		return errors.New("message authentication code mismatch, the document was changed")
+	}
+	return nil
+}
+
+// sopsMAC computes the SOPS message authentication code of a document
+// It is the sha512 of every value except the sops metadata in document order,
+// encrypted values are hashed as their plain value
+// yaml_data: the document
+// key: the data key to decrypt the encrypted values
+// return: the mac as upper case hex, error
+func sopsMAC(yaml_data string, key []byte) (string, error) {
+	var doc yaml_v3.Node
+	if err := yaml_v3.Unmarshal([]byte(yaml_data), &doc); err != nil {
+		return "", err
+	}
+	h := sha512.New()
+	if err := hashNode(h, &doc, nil, key); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%X", h.Sum(nil)), nil
+}
+
+// hashNode adds the values of a yaml node to the mac in document order
+// h: the hash
+// node: the yaml node
+// path: the map keys to the node, nil for the document
+// key: the data key
+// return: error
+func hashNode(h hash.Hash, node *yaml_v3.Node, path []string, key []byte) error {
+	switch node.Kind {
+	case yaml_v3.DocumentNode:
+		for _, n := range node.Content {
+			if err := hashNode(h, n, []string{}, key); err != nil {
+				return err
+			}
+		}
+	case yaml_v3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k := node.Content[i].Value
+			if len(path) == 0 && k == "sops" {
+				continue
+			}
+			if err := hashNode(h, node.Content[i+1], append(append([]string{}, path...), k), key); err != nil {
+				return err
+			}
+		}
+	case yaml_v3.SequenceNode:
+		for _, n := range node.Content {
+			if err := hashNode(h, n, path, key); err != nil {
+				return err
+			}
+		}
+	case yaml_v3.AliasNode:
+		return hashNode(h, node.Alias, path, key)
+	case yaml_v3.ScalarNode:
+		if encryptedValue.MatchString(node.Value) {
+			plain, _, err := decryptBytes(key, node.Value, strings.Join(path, ":")+":")
+			if err != nil {
+				return err
+			}
+			h.Write(plain)
+			return nil
+		}
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		// the same conversion as sops
+		switch v := value.(type) {
+		case nil:
+		case string:
+			h.Write([]byte(v))
+		case int:
+			h.Write([]byte(strconv.Itoa(v)))
+		case float64:
+			h.Write([]byte(strconv.FormatFloat(v, 'f', -1, 64)))
+		case bool:
+			if v {
+				h.Write([]byte("True"))
+			} else {
+				h.Write([]byte("False"))
+			}
+		default:
+			h.Write([]byte(node.Value))
+		}
+	}
+	return nil
+}
+
+// walkValues calls fn for every string value in the tree
+// the value returned by fn replaces the string
+// value: the tree to walk
+// path: the map keys to the value
+// fn: the function to call
+// return: the new value, error
+func walkValues(value interface{}, path []string, fn func(value string, path []string) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if len(path) == 0 && k == "sops" {
+				continue
+			}
+			new_item, err := walkValues(item, append(append([]string{}, path...), k), fn)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = new_item
+		}
+	case []interface{}:
+		for i, item := range v {
+			new_item, err := walkValues(item, path, fn)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = new_item
+		}
+	case string:
+		return fn(v, path)
+	}
+	return value, nil
+}
+
+// decryptValue decrypts a SOPS style ENC[AES256_GCM,...] value
+// key: the data key
+// value: the encrypted value
+// additional_data: the path of the value joined with :
+// return: the plain value, error
+func decryptValue(key []byte, value string, additional_data string) (interface{}, error) {
+	plain, value_type, err := decryptBytes(key, value, additional_data)
+	if err != nil {
+		return nil, err
+	}
+	switch value_type {
+	case "int":
+		return strconv.ParseInt(string(plain), 10, 64)
+	case "float":
+		return strconv.ParseFloat(string(plain), 64)
+	case "bool":
+		return strconv.ParseBool(string(plain))
+	}
+	return string(plain), nil
+}
+
+// decryptBytes decrypts a SOPS style ENC[AES256_GCM,...] value
+// key: the data key
+// value: the encrypted value
+// additional_data: the authenticated data the value was encrypted with
+// return: the plain bytes, the value type, error
+func decryptBytes(key []byte, value string, additional_data string) ([]byte, string, error) {
+	parts := encryptedValue.FindStringSubmatch(value)
+	if parts == nil {
+		return nil, "", errors.New("value is not encrypted")
+	}
+	data, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, "", err
+	}
+	iv, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, "", err
+	}
+	tag, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, "", err
+	}
+	plain, err := gcm.Open(nil, iv, append(data, tag...), []byte(additional_data))
+	if err != nil {
+		return nil, "", errors.New("unable to decrypt value, wrong key or the value was changed")
+	}
+	return plain, parts[4], nil
+}
+
+// encryptValue encrypts a string as a SOPS style ENC[AES256_GCM,...] value
+// key: the data key
+// value: the plain value
+// additional_data: the path of the value joined with : or the last modified time for the mac
+// return: the encrypted value, error
+func encryptValue(key []byte, value string, additional_data string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, 32)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, []byte(value), []byte(additional_data))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:str]",
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag)), nil
+}
+
+// parseSecretKey reads a 32 byte key from raw bytes or base64
+// A raw key can hold whitespace bytes so only the line ending is stripped from it
+// data: the key file contents
+// return: the key, error
+func parseSecretKey(data []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(data)
+	if key, err := base64.StdEncoding.DecodeString(string(trimmed)); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	raw := bytes.TrimSuffix(data, []byte("\n"))
+	raw = bytes.TrimSuffix(raw, []byte("\r"))
+	if len(raw) == 32 {
+		return raw, nil
+	}
+	return nil, errors.New("secret key must be 32 bytes or 32 bytes base64 encoded")
+}
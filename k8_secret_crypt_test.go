@@ -0,0 +1,279 @@
+package go_k8_helm
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"fmt"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+const testSecretYaml = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: default
+type: Opaque
+data:
+  password: cGFzc3dvcmQ=
+  user: YWRtaW4=
+stringData:
+  host: db.example.com
+`
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestEncryptDecryptValue(t *testing.T) {
+	key := testKey(1)
+	tests := []struct {
+		name     string
+		value    string
+		key      []byte
+		aad      string
+		wantErr  bool
+		wantText string
+	}{
+		{name: "round trip", value: "secret", key: key, aad: "data:password:", wantText: "secret"},
+		{name: "empty value", value: "", key: key, aad: "data:password:", wantText: ""},
+		{name: "wrong key", value: "secret", key: testKey(2), aad: "data:password:", wantErr: true},
+		{name: "moved to another path", value: "secret", key: key, aad: "data:user:", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := encryptValue(key, tt.value, "data:password:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !encryptedValue.MatchString(enc) {
+				t.Fatalf("encrypted value %q is not in the ENC format", enc)
+			}
+			if strings.Contains(enc, tt.value) && tt.value != "" {
+				t.Fatalf("encrypted value %q holds the plain value", enc)
+			}
+			got, err := decryptValue(tt.key, enc, tt.aad)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decryptValue() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && got != tt.wantText {
+				t.Fatalf("decryptValue() = %v, want %v", got, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestDecryptValueTypes(t *testing.T) {
+	key := testKey(1)
+	tests := []struct {
+		plain string
+		typ   string
+		want  interface{}
+	}{
+		{plain: "42", typ: "int", want: int64(42)},
+		{plain: "1.5", typ: "float", want: 1.5},
+		{plain: "True", typ: "bool", want: true},
+		{plain: "text", typ: "str", want: "text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			enc, err := encryptValue(key, tt.plain, "a:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			enc = strings.Replace(enc, "type:str]", "type:"+tt.typ+"]", 1)
+			got, err := decryptValue(key, enc, "a:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("decryptValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSecretKey(t *testing.T) {
+	raw := testKey('k')
+	raw_space := append(bytes.Repeat([]byte{'a'}, 31), ' ')
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{name: "base64", data: []byte("a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s="), want: raw},
+		{name: "base64 with newline", data: []byte("a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=\n"), want: raw},
+		{name: "raw", data: raw, want: raw},
+		{name: "raw with newline", data: append(append([]byte{}, raw...), '\n'), want: raw},
+		{name: "raw with crlf", data: append(append([]byte{}, raw...), '\r', '\n'), want: raw},
+		{name: "raw ending in a space", data: raw_space, want: raw_space},
+		{name: "raw ending in a space with newline", data: append(append([]byte{}, raw_space...), '\n'), want: raw_space},
+		{name: "too short", data: []byte("short"), wantErr: true},
+		{name: "base64 too short", data: []byte("c2hvcnQ="), wantErr: true},
+		{name: "empty", data: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSecretKey(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSecretKey() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("parseSecretKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptSecretYamlRoundTrip(t *testing.T) {
+	key := testKey(3)
+	enc, err := EncryptSecretYaml(testSecretYaml, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plain := range []string{"cGFzc3dvcmQ=", "YWRtaW4=", "db.example.com"} {
+		if strings.Contains(enc, plain) {
+			t.Fatalf("encrypted manifest holds the plain value %q", plain)
+		}
+	}
+
+	obj, _, err := decodeYaml(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &K8{secret_key: key}
+	if err := m.decryptObject(obj, enc); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := obj.Object["sops"]; found {
+		t.Fatal("sops metadata was not removed")
+	}
+	if got := obj.Object["data"].(map[string]interface{})["password"]; got != "cGFzc3dvcmQ=" {
+		t.Fatalf("password = %v", got)
+	}
+	if got := obj.Object["stringData"].(map[string]interface{})["host"]; got != "db.example.com" {
+		t.Fatalf("host = %v", got)
+	}
+}
+
+func TestEncryptSecretYamlAgeRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := EncryptSecretYamlAge(testSecretYaml, identity.Recipient().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj, _, err := decodeYaml(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &K8{age_identities: []age.Identity{identity}}
+	if err := m.decryptObject(obj, enc); err != nil {
+		t.Fatal(err)
+	}
+	if got := obj.Object["data"].(map[string]interface{})["user"]; got != "YWRtaW4=" {
+		t.Fatalf("user = %v", got)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, _, _ = decodeYaml(enc)
+	m = &K8{age_identities: []age.Identity{other}}
+	if err := m.decryptObject(obj, enc); err == nil {
+		t.Fatal("decrypted with an identity that is not a recipient")
+	}
+}
+
+func TestDecryptObjectMAC(t *testing.T) {
+	key := testKey(4)
+	enc, err := EncryptSecretYaml(testSecretYaml, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := EncryptSecretYaml(strings.Replace(testSecretYaml, "cGFzc3dvcmQ=", "b3RoZXI=", 1), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := func(doc string, prefix string) string {
+		for _, l := range strings.Split(doc, "\n") {
+			if strings.HasPrefix(l, prefix) {
+				return l
+			}
+		}
+		t.Fatalf("no line %q", prefix)
+		return ""
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(string) string
+	}{
+		{name: "value swapped from another document", mutate: func(doc string) string {
+			return strings.Replace(doc, line(doc, "  password:"), line(other, "  password:"), 1)
+		}},
+		{name: "value dropped", mutate: func(doc string) string {
+			return strings.Replace(doc, line(doc, "  user:")+"\n", "", 1)
+		}},
+		{name: "plain value changed", mutate: func(doc string) string {
+			return strings.Replace(doc, "name: db", "name: other", 1)
+		}},
+		{name: "value added", mutate: func(doc string) string {
+			return strings.Replace(doc, "type: Opaque", "type: Opaque\nimmutable: true", 1)
+		}},
+		{name: "mac removed", mutate: func(doc string) string {
+			return strings.Replace(doc, line(doc, "  mac:")+"\n", "", 1)
+		}},
+		{name: "last modified changed", mutate: func(doc string) string {
+			return strings.Replace(doc, line(doc, "  lastmodified:"), `  lastmodified: "2000-01-01T00:00:00Z"`, 1)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := tt.mutate(enc)
+			if doc == enc {
+				t.Fatal("the document was not changed")
+			}
+			obj, _, err := decodeYaml(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := &K8{secret_key: key}
+			if err := m.decryptObject(obj, doc); err == nil {
+				t.Fatal("decryptObject() accepted a changed document")
+			}
+		})
+	}
+}
+
+func TestSopsMACKeyOrder(t *testing.T) {
+	a, err := sopsMAC("a: 1\nb: true\nc: x\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := sopsMAC("b: true\na: 1\nc: x\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Fatal("the mac does not follow the document order")
+	}
+	// sops hashes 1, True and x in order
+	if want := fmt.Sprintf("%X", sha512.Sum512([]byte("1Truex"))); a != want {
+		t.Fatalf("sopsMAC() = %s, want %s", a, want)
+	}
+	c, err := sopsMAC("a: 1\nb: true\nc: x\nsops:\n  version: 3.7.3\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != c {
+		t.Fatal("the sops metadata is part of the mac")
+	}
+}
@@ -0,0 +1,146 @@
+package go_k8_helm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// testCertificate creates a self signed certificate and its key as PEM
+func testCertificate(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key_der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key_der})
+}
+
+func TestNewTLSSecret(t *testing.T) {
+	cert, key := testCertificate(t)
+	_, other_key := testCertificate(t)
+	tests := []struct {
+		name    string
+		cert    []byte
+		key     []byte
+		wantErr bool
+	}{
+		{name: "valid", cert: cert, key: key},
+		{name: "key does not match", cert: cert, key: other_key, wantErr: true},
+		{name: "certificate not PEM", cert: []byte("not a certificate"), key: key, wantErr: true},
+		{name: "key not PEM", cert: cert, key: []byte("not a key"), wantErr: true},
+		{name: "key given as certificate", cert: key, key: key, wantErr: true},
+		{name: "certificate given as key", cert: cert, key: cert, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := NewTLSSecret("default", "tls", tt.cert, tt.key, OptionSecretImmutable(true))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTLSSecret() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if secret.Type != v1.SecretTypeTLS || string(secret.Data[v1.TLSCertKey]) != string(cert) || string(secret.Data[v1.TLSPrivateKeyKey]) != string(key) {
+				t.Fatalf("NewTLSSecret() = %+v", secret)
+			}
+			if secret.Immutable == nil || !*secret.Immutable {
+				t.Fatal("the immutable option was not applied")
+			}
+		})
+	}
+}
+
+func TestNewDockerConfigSecret(t *testing.T) {
+	secret, err := NewDockerConfigSecret("default", "pull", "ghcr.io", "user", "token", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Auths map[string]map[string]string `json:"auths"`
+	}
+	if err := json.Unmarshal(secret.Data[v1.DockerConfigJsonKey], &config); err != nil {
+		t.Fatal(err)
+	}
+	auth := config.Auths["ghcr.io"]
+	if auth["auth"] != "dXNlcjp0b2tlbg==" || auth["username"] != "user" {
+		t.Fatalf("auth = %v", auth)
+	}
+	if _, found := auth["email"]; found {
+		t.Fatal("an empty email was written")
+	}
+
+	if _, err := NewDockerConfigSecret("default", "pull", "ghcr.io", "", "token", ""); err == nil {
+		t.Fatal("a docker config without a user was built")
+	}
+}
+
+func TestNewGenericSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []SecretOption
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "literals", opts: []SecretOption{OptionSecretLiteral("a", "1"), OptionSecretLiteral("b", "2")}, want: map[string]string{"a": "1", "b": "2"}},
+		{name: "duplicate key", opts: []SecretOption{OptionSecretLiteral("a", "1"), OptionSecretLiteral("a", "2")}, wantErr: true},
+		{name: "invalid key", opts: []SecretOption{OptionSecretLiteral("a/b", "1")}, wantErr: true},
+		{name: "missing file", opts: []SecretOption{OptionSecretFile("/does/not/exist")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := NewGenericSecret("default", "app", tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGenericSecret() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if secret.Type != v1.SecretTypeOpaque || len(secret.Data) != len(tt.want) {
+				t.Fatalf("NewGenericSecret() = %+v", secret)
+			}
+			for k, v := range tt.want {
+				if string(secret.Data[k]) != v {
+					t.Fatalf("%s = %s, want %s", k, secret.Data[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestNewBasicAuthSecret(t *testing.T) {
+	if _, err := NewBasicAuthSecret("default", "auth", "", ""); err == nil {
+		t.Fatal("a basic auth secret without a user or password was built")
+	}
+	secret, err := NewBasicAuthSecret("default", "auth", "user", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Type != v1.SecretTypeBasicAuth || string(secret.Data[v1.BasicAuthUsernameKey]) != "user" {
+		t.Fatalf("NewBasicAuthSecret() = %+v", secret)
+	}
+}
@@ -0,0 +1,165 @@
+package go_k8_helm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+// redacted is printed in place of a secret value
+const redacted = "***"
+
+// SecretValue is a decoded secret value that prints as *** in logs, fmt verbs, JSON and YAML
+// Use Reveal or Bytes to read the value
+type SecretValue struct {
+	value []byte
+}
+
+// NewSecretValue wraps a value so it is redacted when printed
+func NewSecretValue(value []byte) SecretValue {
+	return SecretValue{value: value}
+}
+
+// Reveal returns the value as a string
+func (s SecretValue) Reveal() string {
+	return string(s.value)
+}
+
+// Bytes returns the value
+func (s SecretValue) Bytes() []byte {
+	return s.value
+}
+
+// String returns ***
+func (s SecretValue) String() string {
+	return redacted
+}
+
+// GoString returns *** for %#v
+func (s SecretValue) GoString() string {
+	return redacted
+}
+
+// Format writes *** for every fmt verb
+func (s SecretValue) Format(f fmt.State, verb rune) {
+	io.WriteString(f, redacted)
+}
+
+// MarshalJSON returns "***"
+func (s SecretValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+// MarshalText returns ***
+func (s SecretValue) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// MarshalYAML returns *** for yaml.v2 and yaml.v3
+func (s SecretValue) MarshalYAML() (interface{}, error) {
+	return redacted, nil
+}
+
+// GetSecretData gets all the decoded values of a secret
+// ns: namespace
+// name: name of the secret
+// return: key to value, error
+func (m *K8) GetSecretData(ns string, name string) (map[string]SecretValue, error) {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client_set.CoreV1().Secrets(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]SecretValue{}
+	for k, v := range secret.Data {
+		data[k] = NewSecretValue(v)
+	}
+	for k, v := range secret.StringData {
+		data[k] = NewSecretValue([]byte(v))
+	}
+	return data, nil
+}
+
+// GetSecretValue gets a decoded value of a secret
+// The key can have a JSONPath into a JSON or YAML value e.g. config.json:$.auths or .dockerconfigjson:{.auths.*.auth}
+// ns: namespace
+// name: name of the secret
+// key: the key with an optional :path
+// return: the value, error
+func (m *K8) GetSecretValue(ns string, name string, key string) (SecretValue, error) {
+	key, path, _ := strings.Cut(key, ":")
+	data, err := m.GetSecretData(ns, name)
+	if err != nil {
+		return SecretValue{}, err
+	}
+	value, found := data[key]
+	if !found {
+		return SecretValue{}, fmt.Errorf("key %s not found in secret %s", key, name)
+	}
+	if path == "" {
+		return value, nil
+	}
+	extracted, err := ExtractValue(value.Bytes(), path)
+	if err != nil {
+		return SecretValue{}, fmt.Errorf("key %s: %w", key, err)
+	}
+	return NewSecretValue(extracted), nil
+}
+
+// ExtractValue gets a value from a JSON or YAML document using a JSONPath
+// Strings are returned as is, other values and multiple results are returned as JSON
+// data: the JSON or YAML document
+// path: the JSONPath e.g. $.auths, .auths or {.auths}
+// return: the value, error
+func ExtractValue(data []byte, path string) ([]byte, error) {
+	json_data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("value is not JSON or YAML: %w", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(json_data, &doc); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(path, "{") {
+		if !strings.HasPrefix(path, "$") && !strings.HasPrefix(path, ".") {
+			path = "." + path
+		}
+		path = "{" + path + "}"
+	}
+	jp := jsonpath.New("value")
+	if err := jp.Parse(path); err != nil {
+		return nil, err
+	}
+	results, err := jp.FindResults(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []interface{}
+	for _, r := range results {
+		for _, v := range r {
+			values = append(values, v.Interface())
+		}
+	}
+	switch len(values) {
+	case 0:
+		return nil, fmt.Errorf("path %s not found", path)
+	case 1:
+		if s, ok := values[0].(string); ok {
+			return []byte(s), nil
+		}
+		return json.Marshal(values[0])
+	}
+	return json.Marshal(values)
+}
@@ -0,0 +1,87 @@
+package go_k8_helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	yamlv3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/yaml"
+)
+
+func TestExtractValue(t *testing.T) {
+	docker_config := `{"auths":{"ghcr.io":{"auth":"dXNlcjp0b2tlbg==","username":"user"}}}`
+	tests := []struct {
+		name    string
+		data    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "string", data: docker_config, path: ".auths.ghcr\\.io.username", want: "user"},
+		{name: "dollar path", data: docker_config, path: "$.auths.ghcr\\.io.auth", want: "dXNlcjp0b2tlbg=="},
+		{name: "braces path", data: docker_config, path: "{.auths.*.auth}", want: "dXNlcjp0b2tlbg=="},
+		{name: "bare path", data: docker_config, path: "auths.ghcr\\.io.username", want: "user"},
+		{name: "object as json", data: docker_config, path: ".auths", want: `{"ghcr.io":{"auth":"dXNlcjp0b2tlbg==","username":"user"}}`},
+		{name: "yaml document", data: "db:\n  port: 5432\n  hosts: [a, b]\n", path: ".db.port", want: "5432"},
+		{name: "multiple results", data: "db:\n  hosts: [a, b]\n", path: "{.db.hosts[*]}", want: `["a","b"]`},
+		{name: "bool", data: `{"tls":true}`, path: ".tls", want: "true"},
+		{name: "missing key", data: docker_config, path: ".missing", wantErr: true},
+		{name: "no results", data: `{"list":[]}`, path: "{.list[*]}", wantErr: true},
+		{name: "invalid path", data: docker_config, path: "{.auths[", wantErr: true},
+		{name: "not a document", data: "a: b: c", path: ".a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractValue([]byte(tt.data), tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtractValue() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Fatalf("ExtractValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretValueRedacted(t *testing.T) {
+	value := NewSecretValue([]byte("hunter2"))
+	type holder struct {
+		Password SecretValue `json:"password" yaml:"password"`
+	}
+
+	json_data, err := json.Marshal(holder{Password: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	yaml_data, err := yaml.Marshal(holder{Password: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	yaml_v3_data, err := yamlv3.Marshal(holder{Password: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := map[string]string{
+		"%s":   fmt.Sprintf("%s", value),
+		"%v":   fmt.Sprintf("%v", value),
+		"%+v":  fmt.Sprintf("%+v", holder{Password: value}),
+		"%#v":  fmt.Sprintf("%#v", value),
+		"%x":   fmt.Sprintf("%x", value),
+		"%q":   fmt.Sprintf("%q", value),
+		"map":  fmt.Sprint(map[string]SecretValue{"password": value}),
+		"json": string(json_data),
+		"yaml": string(yaml_data),
+		"v3":   string(yaml_v3_data),
+	}
+	for name, out := range outputs {
+		if strings.Contains(out, "hunter2") || strings.Contains(out, fmt.Sprintf("%x", "hunter2")) || !strings.Contains(out, redacted) {
+			t.Errorf("%s printed the value: %s", name, out)
+		}
+	}
+
+	if value.Reveal() != "hunter2" || string(value.Bytes()) != "hunter2" {
+		t.Fatal("the value could not be read")
+	}
+}
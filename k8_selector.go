@@ -0,0 +1,60 @@
+package go_k8_helm
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ListOptions are the options used when listing resources
+// LabelSelector selects by label e.g. app=web,tier!=cache
+// FieldSelector selects by field e.g. status.phase=Running
+type ListOptions struct {
+	LabelSelector string `json:"label_selector" yaml:"label_selector"`
+	FieldSelector string `json:"field_selector" yaml:"field_selector"`
+}
+
+// ListOption is the option for a list
+type ListOption func(*ListOptions)
+
+// OptionListLabelSelector is the option for the label selector
+// selector: the label selector e.g. app=web,tier!=cache
+func OptionListLabelSelector(selector string) ListOption {
+	return func(o *ListOptions) {
+		o.LabelSelector = selector
+	}
+}
+
+// OptionListFieldSelector is the option for the field selector
+// selector: the field selector e.g. status.phase=Running
+func OptionListFieldSelector(selector string) ListOption {
+	return func(o *ListOptions) {
+		o.FieldSelector = selector
+	}
+}
+
+// newListOptions builds the k8 list options and checks the selectors
+// opts: the list options
+// return: metav1.ListOptions, error if a selector is not valid
+func newListOptions(opts []ListOption) (metav1.ListOptions, error) {
+	o := &ListOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.LabelSelector != "" {
+		if _, err := labels.Parse(o.LabelSelector); err != nil {
+			return metav1.ListOptions{}, fmt.Errorf("invalid label selector %s: %w", o.LabelSelector, err)
+		}
+	}
+	if o.FieldSelector != "" {
+		if _, err := fields.ParseSelector(o.FieldSelector); err != nil {
+			return metav1.ListOptions{}, fmt.Errorf("invalid field selector %s: %w", o.FieldSelector, err)
+		}
+	}
+	return metav1.ListOptions{
+		LabelSelector: o.LabelSelector,
+		FieldSelector: o.FieldSelector,
+	}, nil
+}
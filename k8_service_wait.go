@@ -0,0 +1,141 @@
+package go_k8_helm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// maxWarningEvents is the number of warning events attached to a ServiceAddressError
+const maxWarningEvents = 5
+
+// ServiceAddressError is returned when a service did not get a load balancer address in time
+// Events are the latest warning events of the service e.g. from the cloud controller
+type ServiceAddressError struct {
+	Namespace string
+	Name      string
+	Timeout   time.Duration
+	Events    []string
+}
+
+// Error returns the error with the warning events
+func (e *ServiceAddressError) Error() string {
+	msg := fmt.Sprintf("service %s in namespace %s did not get a load balancer address in %s", e.Name, e.Namespace, e.Timeout)
+	if len(e.Events) > 0 {
+		msg += ": " + strings.Join(e.Events, "; ")
+	}
+	return msg
+}
+
+// WaitForServiceAddress watches a LoadBalancer service until it has an ingress ip or hostname
+// On timeout a *ServiceAddressError is returned with the latest warning events of the service
+// ns: namespace
+// name: name of the service
+// timeout: how long to wait
+// return: the ip or hostname, error
+func (m *K8) WaitForServiceAddress(ns string, name string, timeout time.Duration) (string, error) {
+	client_set, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return "", err
+	}
+	client := client_set.CoreV1().Services(ns)
+
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return client.List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return client.Watch(context.TODO(), options)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	address := ""
+	exists := func(store cache.Store) (bool, error) {
+		if _, found, err := store.GetByKey(ns + "/" + name); err != nil || found {
+			return false, err
+		}
+		return true, apierrors.NewNotFound(v1.Resource("services"), name)
+	}
+	_, err = watchtools.UntilWithSync(ctx, lw, &v1.Service{}, exists, func(event watch.Event) (bool, error) {
+		if event.Type == watch.Deleted {
+			return false, fmt.Errorf("service %s was deleted", name)
+		}
+		service, ok := event.Object.(*v1.Service)
+		if !ok {
+			return false, nil
+		}
+		if service.Spec.Type != v1.ServiceTypeLoadBalancer {
+			return false, fmt.Errorf("service %s is type %s not LoadBalancer", name, service.Spec.Type)
+		}
+		for _, i := range service.Status.LoadBalancer.Ingress {
+			if i.IP != "" {
+				address = i.IP
+				return true, nil
+			}
+			if i.Hostname != "" {
+				address = i.Hostname
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err == nil {
+		return address, nil
+	}
+	if ctx.Err() == nil {
+		return "", err
+	}
+
+	//*********************************
+	//Attach the warnings to the timeout
+	//*********************************
+	addr_err := &ServiceAddressError{Namespace: ns, Name: name, Timeout: timeout}
+	events, list_err := client_set.CoreV1().Events(ns).List(context.TODO(), metav1.ListOptions{
+		FieldSelector: fields.Set{
+			"involvedObject.kind": "Service",
+			"involvedObject.name": name,
+			"type":                v1.EventTypeWarning,
+		}.String(),
+	})
+	if list_err == nil {
+		items := events.Items
+		sort.Slice(items, func(i, j int) bool {
+			return eventTime(&items[i]).Before(eventTime(&items[j]))
+		})
+		if len(items) > maxWarningEvents {
+			items = items[len(items)-maxWarningEvents:]
+		}
+		for _, e := range items {
+			addr_err.Events = append(addr_err.Events, fmt.Sprintf("%s: %s", e.Reason, e.Message))
+		}
+	}
+	return "", addr_err
+}
+
+// eventTime returns the last time an event happened
+func eventTime(e *v1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
@@ -0,0 +1,160 @@
+package go_k8_helm
+
+import (
+	"fmt"
+	"log"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// TransactionObject is an object that was touched by a transactional apply
+type TransactionObject struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+	Created   bool   `json:"created" yaml:"created"` //true if the object did not exist before the apply
+}
+
+// TransactionFailure is an object that could not be restored during a rollback
+type TransactionFailure struct {
+	Object TransactionObject `json:"object" yaml:"object"`
+	Error  string            `json:"error" yaml:"error"`
+}
+
+// TransactionReport is the result of a transactional apply
+// Applied is the list of objects that were applied in order
+// RolledBack is the list of objects that were restored or removed
+// Failed is the list of objects that could not be restored
+type TransactionReport struct {
+	Applied    []TransactionObject  `json:"applied" yaml:"applied"`
+	RolledBack []TransactionObject  `json:"rolled_back" yaml:"rolled_back"`
+	Failed     []TransactionFailure `json:"failed" yaml:"failed"`
+}
+
+// transactionStep holds the state needed to undo a single applied document
+type transactionStep struct {
+	object   TransactionObject
+	dr       dynamic.ResourceInterface
+	snapshot *unstructured.Unstructured
+}
+
+// ProcessK8FileTransaction applies a k8 file with multiple definitions separated with ---
+// The live state of each object is saved before it is changed
+// If a document fails to apply the saved objects are restored
+// and newly created objects are deleted in reverse order
+// file_data: file data
+// ns: namespace
+// return: *TransactionReport, error
+func (m *K8) ProcessK8FileTransaction(file_data []byte, ns string) (*TransactionReport, error) {
+	report := &TransactionReport{}
+	var steps []transactionStep
+
+	parts := m.splitK8File(file_data, ns, true)
+	if err := m.checkPartsPolicies(parts, ns); err != nil {
+		return report, err
+	}
+	for _, o := range parts {
+		if o == "" {
+			continue
+		}
+
+		//***************************
+		//Snapshot the current object
+		//***************************
+		obj, dr, err := m.prepareYaml(o, ns)
+		if err != nil {
+			return report, m.rollback(report, steps, err)
+		}
+		step := transactionStep{
+			object: TransactionObject{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()},
+			dr:     dr,
+		}
+		live, err := dr.Get(m.ctx, obj.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			step.object.Created = true
+		} else if err != nil {
+			return report, m.rollback(report, steps, err)
+		} else {
+			step.snapshot = live
+		}
+
+		//****************
+		//Apply the object
+		//****************
+		err = m.applyYaml(o, ns)
+		if !m.dry_run {
+			//Keep the step even on failure as the apply may have changed the object
+			steps = append(steps, step)
+		}
+		if err != nil {
+			return report, m.rollback(report, steps, err)
+		}
+		report.Applied = append(report.Applied, step.object)
+	}
+	return report, nil
+}
+
+// rollback undoes the applied steps in reverse order
+// report: the report to update
+// steps: the steps that were applied
+// cause: the error that caused the rollback
+// return: error describing the failure
+func (m *K8) rollback(report *TransactionReport, steps []transactionStep, cause error) error {
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		var err error
+		if step.object.Created {
+			log.Printf("Info: Rollback deleting Kind(%s) Namespace(%s) Name(%s)\n", step.object.Kind, step.object.Namespace, step.object.Name)
+			deletePolicy := metav1.DeletePropagationForeground
+			err = step.dr.Delete(m.ctx, step.object.Name, metav1.DeleteOptions{
+				PropagationPolicy: &deletePolicy,
+			})
+			if apierrors.IsNotFound(err) {
+				err = nil
+			}
+		} else {
+			log.Printf("Info: Rollback restoring Kind(%s) Namespace(%s) Name(%s)\n", step.object.Kind, step.object.Namespace, step.object.Name)
+			err = m.restoreObject(step.dr, step.snapshot)
+		}
+		if err != nil {
+			report.Failed = append(report.Failed, TransactionFailure{Object: step.object, Error: err.Error()})
+			continue
+		}
+		report.RolledBack = append(report.RolledBack, step.object)
+	}
+
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("apply failed and %d object(s) could not be rolled back: %w", len(report.Failed), cause)
+	}
+	return fmt.Errorf("apply failed and was rolled back: %w", cause)
+}
+
+// restoreObject puts back a saved object
+// dr: the resource interface of the object
+// snapshot: the object as it was before the apply
+// return: error
+func (m *K8) restoreObject(dr dynamic.ResourceInterface, snapshot *unstructured.Unstructured) error {
+	restore := snapshot.DeepCopy()
+	restore.SetManagedFields(nil)
+
+	current, err := dr.Get(m.ctx, restore.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		//The object was removed so create it again
+		restore.SetResourceVersion("")
+		restore.SetUID("")
+		_, err = dr.Create(m.ctx, restore, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	//The object may have been recreated so use the current identity
+	restore.SetResourceVersion(current.GetResourceVersion())
+	restore.SetUID(current.GetUID())
+	_, err = dr.Update(m.ctx, restore, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,208 @@
+package go_k8_helm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testConfigMapResource is the resource of the configmaps in the transaction tests
+var testConfigMapResource = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// testConfigMap builds an unstructured configmap in the app namespace
+func testConfigMap(name string, value string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "app"},
+		"data":       map[string]interface{}{"value": value},
+	}}
+}
+
+// testDynamicClient builds a fake dynamic client with the objects
+func testDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{testConfigMapResource: "ConfigMapList"}, objects...)
+}
+
+// testTransaction applies the changes to the client as a transaction would
+// before are the objects as they were before the apply, nil for a created object
+// after are the objects as they were applied
+func testTransaction(t *testing.T, client *dynamicfake.FakeDynamicClient, before []*unstructured.Unstructured, after []*unstructured.Unstructured) []transactionStep {
+	t.Helper()
+	dr := client.Resource(testConfigMapResource).Namespace("app")
+	var steps []transactionStep
+	for i, obj := range after {
+		step := transactionStep{
+			object: TransactionObject{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()},
+			dr:     dr,
+		}
+		var err error
+		if before[i] == nil {
+			step.object.Created = true
+			_, err = dr.Create(context.TODO(), obj, metav1.CreateOptions{})
+		} else {
+			step.snapshot, err = dr.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+			if err == nil {
+				_, err = dr.Update(context.TODO(), obj, metav1.UpdateOptions{})
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		steps = append(steps, step)
+	}
+	client.ClearActions()
+	return steps
+}
+
+// testActions returns verb:name for each action on the client
+func testActions(client *dynamicfake.FakeDynamicClient) []string {
+	var out []string
+	for _, a := range client.Actions() {
+		switch action := a.(type) {
+		case k8stesting.DeleteAction:
+			out = append(out, a.GetVerb()+":"+action.GetName())
+		case k8stesting.CreateAction:
+			out = append(out, a.GetVerb()+":"+action.GetObject().(*unstructured.Unstructured).GetName())
+		}
+	}
+	return out
+}
+
+func TestRollback(t *testing.T) {
+	client := testDynamicClient(testConfigMap("existing", "old"))
+	steps := testTransaction(t, client,
+		[]*unstructured.Unstructured{nil, testConfigMap("existing", "old"), nil},
+		[]*unstructured.Unstructured{testConfigMap("first", "new"), testConfigMap("existing", "new"), testConfigMap("last", "new")},
+	)
+
+	m := &K8{}
+	report := &TransactionReport{}
+	cause := errors.New("apply failed")
+	err := m.rollback(report, steps, cause)
+	if !errors.Is(err, cause) {
+		t.Fatalf("rollback() error = %v, want it to wrap the cause", err)
+	}
+
+	//The steps are undone in reverse order
+	want := []string{"delete:last", "update:existing", "delete:first"}
+	if got := testActions(client); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rollback() actions = %v, want %v", got, want)
+	}
+	var rolled_back []string
+	for _, o := range report.RolledBack {
+		rolled_back = append(rolled_back, o.Name)
+	}
+	if !reflect.DeepEqual(rolled_back, []string{"last", "existing", "first"}) || len(report.Failed) != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	//The created objects are gone and the existing object has its old value
+	dr := client.Resource(testConfigMapResource).Namespace("app")
+	for _, name := range []string{"first", "last"} {
+		if _, err := dr.Get(context.TODO(), name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Fatalf("created %s was not deleted: %v", name, err)
+		}
+	}
+	existing, err := dr.Get(context.TODO(), "existing", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _, _ := unstructured.NestedString(existing.Object, "data", "value"); value != "old" {
+		t.Fatalf("existing = %s, want old", value)
+	}
+}
+
+func TestRollbackFailure(t *testing.T) {
+	client := testDynamicClient(testConfigMap("existing", "old"))
+	steps := testTransaction(t, client,
+		[]*unstructured.Unstructured{nil, testConfigMap("existing", "old")},
+		[]*unstructured.Unstructured{testConfigMap("created", "new"), testConfigMap("existing", "new")},
+	)
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(testConfigMapResource.GroupResource(), "existing", errors.New("denied"))
+	})
+
+	m := &K8{}
+	report := &TransactionReport{}
+	err := m.rollback(report, steps, errors.New("apply failed"))
+	if err == nil {
+		t.Fatal("rollback() did not return an error")
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Object.Name != "existing" || !strings.Contains(report.Failed[0].Error, "denied") {
+		t.Fatalf("report failed = %+v", report.Failed)
+	}
+	//A failure does not stop the rest of the rollback
+	if len(report.RolledBack) != 1 || report.RolledBack[0].Name != "created" {
+		t.Fatalf("report rolled back = %+v", report.RolledBack)
+	}
+}
+
+func TestRollbackCreatedAlreadyGone(t *testing.T) {
+	client := testDynamicClient()
+	steps := testTransaction(t, client, []*unstructured.Unstructured{nil}, []*unstructured.Unstructured{testConfigMap("created", "new")})
+	dr := client.Resource(testConfigMapResource).Namespace("app")
+	if err := dr.Delete(context.TODO(), "created", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &K8{}
+	report := &TransactionReport{}
+	m.rollback(report, steps, errors.New("apply failed"))
+	if len(report.Failed) != 0 || len(report.RolledBack) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestRestoreObject(t *testing.T) {
+	snapshot := testConfigMap("existing", "old")
+	snapshot.SetUID("old-uid")
+	snapshot.SetResourceVersion("1")
+	snapshot.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+
+	tests := []struct {
+		name     string
+		live     []runtime.Object
+		wantVerb string
+	}{
+		{name: "updated", live: []runtime.Object{testConfigMap("existing", "new")}, wantVerb: "update:existing"},
+		{name: "deleted", wantVerb: "create:existing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testDynamicClient(tt.live...)
+			dr := client.Resource(testConfigMapResource).Namespace("app")
+			m := &K8{}
+			if err := m.restoreObject(dr, snapshot); err != nil {
+				t.Fatal(err)
+			}
+			if got := testActions(client); !reflect.DeepEqual(got, []string{tt.wantVerb}) {
+				t.Fatalf("restoreObject() actions = %v, want %s", got, tt.wantVerb)
+			}
+			restored, err := dr.Get(context.TODO(), "existing", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value, _, _ := unstructured.NestedString(restored.Object, "data", "value"); value != "old" {
+				t.Fatalf("restored = %s, want old", value)
+			}
+			if restored.GetUID() == "old-uid" || len(restored.GetManagedFields()) != 0 {
+				t.Fatalf("restored kept the snapshot identity: %+v", restored.Object["metadata"])
+			}
+		})
+	}
+	if snapshot.GetUID() != "old-uid" || len(snapshot.GetManagedFields()) != 1 {
+		t.Fatal("restoreObject() changed the snapshot")
+	}
+}
@@ -3,6 +3,9 @@ package go_k8_helm
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+
+	"filippo.io/age"
 
 	"k8s.io/client-go/rest"
 )
@@ -25,6 +28,14 @@ type K8 struct {
 	Ignore_ssl         bool   `json:"ignore_ssl" yaml:"ignore_ssl" flag:"ignore_ssl i" desc:"If true, ignore the ssl connection"`
 	dry_run            bool
 	verbose            bool
+	namespace_policy   NamespaceMode
+	auto_create_ns     bool
+	policies           []PolicyRule
+	policy_reporter    PolicyReporter
+	mutation           *MutationSpec
+	age_identities     []age.Identity
+	secret_key         []byte
+	cache              atomic.Pointer[informerCache]
 	config             *rest.Config
 	ctx                context.Context
 }
@@ -65,6 +76,41 @@ func (m *K8) SetDryRun(dry_run bool) {
 	m.dry_run = dry_run
 }
 
+// NamespaceMode controls how the namespace passed to ApplyYaml and DeleteYaml
+// is used for namespaced objects
+type NamespaceMode int
+
+const (
+	// NamespaceOverride always sets the namespace, this is the default
+	NamespaceOverride NamespaceMode = iota
+	// NamespaceFillEmpty only sets the namespace when the manifest does not declare one
+	NamespaceFillEmpty
+	// NamespaceRejectMismatch returns an error when the manifest declares a different namespace
+	NamespaceRejectMismatch
+)
+
+// NamespacePolicy returns the namespace policy
+func (m *K8) NamespacePolicy() NamespaceMode {
+	return m.namespace_policy
+}
+
+// SetNamespacePolicy sets the namespace policy
+// Used by ApplyYaml and DeleteYaml for namespaced objects
+func (m *K8) SetNamespacePolicy(policy NamespaceMode) {
+	m.namespace_policy = policy
+}
+
+// AutoCreateNamespace returns the auto create namespace flag
+func (m *K8) AutoCreateNamespace() bool {
+	return m.auto_create_ns
+}
+
+// SetAutoCreateNamespace sets the auto create namespace flag
+// If true, ProcessK8File adds a Namespace definition when the file does not have one
+func (m *K8) SetAutoCreateNamespace(auto_create bool) {
+	m.auto_create_ns = auto_create
+}
+
 // K8Option is the option for the k8 connection
 type K8Option func(*K8)
 
@@ -110,6 +156,20 @@ func OptionK8UseTokenConnection(use_token_connection bool) K8Option {
 	}
 }
 
+// OptionK8NamespacePolicy is the option for the namespace policy
+func OptionK8NamespacePolicy(policy NamespaceMode) K8Option {
+	return func(h *K8) {
+		h.namespace_policy = policy
+	}
+}
+
+// OptionK8AutoCreateNamespace is the option for the auto create namespace
+func OptionK8AutoCreateNamespace(auto_create bool) K8Option {
+	return func(h *K8) {
+		h.auto_create_ns = auto_create
+	}
+}
+
 // Update the k8 Type with the options
 func (m *K8) Update(opts ...K8Option) {
 	// Loop through each option
@@ -0,0 +1,179 @@
+package go_k8_helm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	openapi_v2 "github.com/google/gnostic/openapiv2"
+	yaml_v3 "gopkg.in/yaml.v3"
+	"k8s.io/client-go/discovery"
+	"k8s.io/kube-openapi/pkg/util/proto/validation"
+	"k8s.io/kubectl/pkg/util/openapi"
+)
+
+// Reasons for a schema validation issue
+const (
+	ValidationUnknownField    = "unknown_field"
+	ValidationInvalidType     = "invalid_type"
+	ValidationMissingRequired = "missing_required"
+	ValidationInvalid         = "invalid"
+)
+
+// ValidationIssue is a single problem found when validating a manifest
+// Document is the 1 based position of the document in the file
+// Field is the path to the field with the issue
+// Reason is one of the Validation constants
+type ValidationIssue struct {
+	Document int    `json:"document" yaml:"document"`
+	Kind     string `json:"kind" yaml:"kind"`
+	Name     string `json:"name" yaml:"name"`
+	Field    string `json:"field" yaml:"field"`
+	Reason   string `json:"reason" yaml:"reason"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+// String returns the string representation of the issue
+func (v ValidationIssue) String() string {
+	return fmt.Sprintf("document %d Kind(%s) Name(%s) %s: %s", v.Document, v.Kind, v.Name, v.Field, v.Message)
+}
+
+// ValidateK8File validates a k8 file with multiple definitions separated with ---
+// against the OpenAPI schemas without applying anything
+// The schemas are read from the cluster when connected otherwise from schema_file
+// schema_file is only read, use SaveOpenAPISchema to cache the schemas for offline use
+// Kinds without a schema are skipped
+// file_data: file data
+// schema_file: path to a cached OpenAPI v2 schema file in json or yaml, can be empty
+// return: []ValidationIssue, error
+func (m *K8) ValidateK8File(file_data []byte, schema_file string) ([]ValidationIssue, error) {
+	doc, err := m.openAPISchema(schema_file)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := openapi.NewOpenAPIData(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []ValidationIssue
+	parts := m.splitK8File(file_data, "", false)
+	for i, o := range parts {
+		if o == "" {
+			continue
+		}
+		obj, gvk, err := decodeYaml(o)
+		if err != nil {
+			issues = append(issues, ValidationIssue{Document: i + 1, Reason: ValidationInvalid, Message: err.Error()})
+			continue
+		}
+
+		// SOPS metadata is not part of the schema
+		delete(obj.Object, "sops")
+
+		schema := resources.LookupResource(*gvk)
+		if schema == nil {
+			log.Printf("Info: No schema for Kind(%s) Version(%s) skipping validation\n", gvk.Kind, gvk.GroupVersion().String())
+			continue
+		}
+
+		for _, e := range validation.ValidateModel(obj.Object, schema, gvk.Kind) {
+			issue := validationIssue(e)
+			issue.Document = i + 1
+			issue.Kind = obj.GetKind()
+			issue.Name = obj.GetName()
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// validationIssue converts a schema validation error to a ValidationIssue
+// err: the validation error
+// return: ValidationIssue
+func validationIssue(err error) ValidationIssue {
+	issue := ValidationIssue{Reason: ValidationInvalid, Message: err.Error()}
+
+	var ve validation.ValidationError
+	if !errors.As(err, &ve) {
+		return issue
+	}
+	issue.Field = ve.Path
+	issue.Message = ve.Err.Error()
+
+	switch e := ve.Err.(type) {
+	case validation.UnknownFieldError:
+		issue.Field = ve.Path + "." + e.Field
+		issue.Reason = ValidationUnknownField
+	case validation.MissingRequiredFieldError:
+		issue.Field = ve.Path + "." + e.Field
+		issue.Reason = ValidationMissingRequired
+	case validation.InvalidTypeError:
+		issue.Reason = ValidationInvalidType
+	}
+	return issue
+}
+
+// openAPISchema gets the OpenAPI v2 schema from the cluster or the schema file
+// schema_file: path to a cached schema file, can be empty
+// return: the schema document, error
+func (m *K8) openAPISchema(schema_file string) (*openapi_v2.Document, error) {
+	if m.config != nil {
+		dc, err := discovery.NewDiscoveryClientForConfig(m.config)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := dc.OpenAPISchema()
+		if err == nil {
+			return doc, nil
+		}
+		if schema_file == "" {
+			return nil, err
+		}
+		log.Printf("Info: Unable to get schema from cluster using %s Error(%s)\n", schema_file, err.Error())
+	}
+
+	if schema_file == "" {
+		return nil, errors.New("no cluster connection and no schema file")
+	}
+	data, err := os.ReadFile(schema_file)
+	if err != nil {
+		return nil, err
+	}
+	return openapi_v2.ParseDocument(data)
+}
+
+// SaveOpenAPISchema gets the OpenAPI v2 schema from the cluster and saves it for offline use with ValidateK8File
+// schema_file: path to the schema file, it is overwritten
+// return: error
+func (m *K8) SaveOpenAPISchema(schema_file string) error {
+	if m.config == nil {
+		return errors.New("no cluster connection")
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(m.config)
+	if err != nil {
+		return err
+	}
+	doc, err := dc.OpenAPISchema()
+	if err != nil {
+		return err
+	}
+	if err := saveOpenAPISchema(doc, schema_file); err != nil {
+		return err
+	}
+	log.Printf("Info: Saved schema to %s\n", schema_file)
+	return nil
+}
+
+// saveOpenAPISchema writes the schema document to a file as yaml
+// doc: the schema document
+// schema_file: path to the schema file
+// return: error
+func saveOpenAPISchema(doc *openapi_v2.Document, schema_file string) error {
+	data, err := yaml_v3.Marshal(doc.ToRawInfo())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(schema_file, data, 0644)
+}
@@ -0,0 +1,182 @@
+package go_k8_helm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	openapi_v2 "github.com/google/gnostic/openapiv2"
+	"k8s.io/kube-openapi/pkg/util/proto/validation"
+)
+
+// testSchema is a minimal OpenAPI v2 schema with a ConfigMap that requires data
+const testSchema = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1"},
+  "paths": {},
+  "definitions": {
+    "io.k8s.api.core.v1.ConfigMap": {
+      "type": "object",
+      "required": ["data"],
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "data": {"type": "object", "additionalProperties": {"type": "string"}},
+        "immutable": {"type": "boolean"}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "", "kind": "ConfigMap", "version": "v1"}]
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "namespace": {"type": "string"}
+      }
+    }
+  }
+}`
+
+const testValidateFile = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: good
+data:
+  a: "1"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: bad
+immutable: "yes"
+extra: 1
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: no-schema
+---
+kind: [
+`
+
+// testSchemaFile writes the test schema to a temporary file
+func testSchemaFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(testSchema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// issueReasons returns kind:name:field:reason for each issue
+func issueReasons(issues []ValidationIssue) map[string]bool {
+	out := map[string]bool{}
+	for _, i := range issues {
+		out[i.Kind+":"+i.Name+":"+i.Field+":"+i.Reason] = true
+	}
+	return out
+}
+
+func TestValidateK8FileOffline(t *testing.T) {
+	schema_file := testSchemaFile(t)
+	m := &K8{}
+	issues, err := m.ValidateK8File([]byte(testValidateFile), schema_file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		"ConfigMap:bad:ConfigMap.extra:unknown_field":    true,
+		"ConfigMap:bad:ConfigMap.data:missing_required":  true,
+		"ConfigMap:bad:ConfigMap.immutable:invalid_type": true,
+		":::invalid": true,
+	}
+	if got := issueReasons(issues); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ValidateK8File() = %v, want %v", issues, want)
+	}
+	for _, i := range issues {
+		if i.Name == "bad" && i.Document != 2 {
+			t.Fatalf("issue %v is in document %d, want 2", i, i.Document)
+		}
+	}
+
+	data, err := os.ReadFile(schema_file)
+	if err != nil || string(data) != testSchema {
+		t.Fatalf("the schema file was changed by a validate: %v", err)
+	}
+}
+
+func TestValidateK8FileNoSchema(t *testing.T) {
+	m := &K8{}
+	if _, err := m.ValidateK8File([]byte(testValidateFile), ""); err == nil {
+		t.Fatal("validate without a cluster or a schema file did not fail")
+	}
+	if _, err := m.ValidateK8File([]byte(testValidateFile), filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("validate with a missing schema file did not fail")
+	}
+	if err := m.SaveOpenAPISchema(filepath.Join(t.TempDir(), "schema.yaml")); err == nil {
+		t.Fatal("save without a cluster did not fail")
+	}
+}
+
+func TestSaveOpenAPISchema(t *testing.T) {
+	doc, err := openapi_v2.ParseDocument([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema_file := filepath.Join(t.TempDir(), "schema.yaml")
+	if err := saveOpenAPISchema(doc, schema_file); err != nil {
+		t.Fatal(err)
+	}
+	m := &K8{}
+	issues, err := m.ValidateK8File([]byte(testValidateFile), schema_file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 4 {
+		t.Fatalf("ValidateK8File() with the saved schema = %v", issues)
+	}
+}
+
+func TestValidationIssue(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ValidationIssue
+	}{
+		{
+			name: "unknown field",
+			err:  validation.ValidationError{Path: "ConfigMap", Err: validation.UnknownFieldError{Path: "ConfigMap", Field: "extra"}},
+			want: ValidationIssue{Field: "ConfigMap.extra", Reason: ValidationUnknownField, Message: `unknown field "extra" in ConfigMap`},
+		},
+		{
+			name: "missing required",
+			err:  validation.ValidationError{Path: "Deployment.spec", Err: validation.MissingRequiredFieldError{Path: "Deployment.spec", Field: "selector"}},
+			want: ValidationIssue{Field: "Deployment.spec.selector", Reason: ValidationMissingRequired, Message: `missing required field "selector" in Deployment.spec`},
+		},
+		{
+			name: "invalid type",
+			err:  validation.ValidationError{Path: "Deployment.spec.replicas", Err: validation.InvalidTypeError{Path: "Deployment.spec.replicas", Expected: "integer", Actual: "string"}},
+			want: ValidationIssue{Field: "Deployment.spec.replicas", Reason: ValidationInvalidType, Message: `invalid type for Deployment.spec.replicas: got "string", expected "integer"`},
+		},
+		{
+			name: "other validation error",
+			err:  validation.ValidationError{Path: "ConfigMap", Err: validation.InvalidObjectTypeError{Path: "ConfigMap", Type: "list"}},
+			want: ValidationIssue{Field: "ConfigMap", Reason: ValidationInvalid, Message: `unknown object type "list" in ConfigMap`},
+		},
+		{
+			name: "plain error",
+			err:  errors.New("bad"),
+			want: ValidationIssue{Reason: ValidationInvalid, Message: "bad"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validationIssue(tt.err); got != tt.want {
+				t.Fatalf("validationIssue() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package go_k8_helm
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// WatchEventType is the type of change in a WatchEvent
+type WatchEventType string
+
+// The watch event types
+const (
+	WatchAdded    WatchEventType = "ADDED"
+	WatchModified WatchEventType = "MODIFIED"
+	WatchDeleted  WatchEventType = "DELETED"
+)
+
+// WatchEvent is a change to an object
+type WatchEvent struct {
+	Type   WatchEventType
+	Object *unstructured.Unstructured
+}
+
+// watchRetryDelay is how long to wait before re-establishing a failed watch
+const watchRetryDelay = time.Second
+
+// watcher keeps the state needed to resume a watch
+type watcher struct {
+	ctx              context.Context
+	dr               dynamic.ResourceInterface
+	list_options     metav1.ListOptions
+	resource_version string
+	known            map[string]string
+	events           chan WatchEvent
+}
+
+// Watch watches any kind and sends the changes on a channel
+// The current objects are sent first as added events
+// The watch is re-established from the last resourceVersion when it expires or disconnects
+// If the resourceVersion is too old the objects are listed again and the differences are sent
+// kind: a kind, resource or short name e.g. deploy, ingress or certificates.cert-manager.io
+// ns: namespace, empty for all namespaces
+// selector: label selector e.g. app=web, can be empty
+// return: the event channel, stop function, error
+func (m *K8) Watch(kind string, ns string, selector string) (<-chan WatchEvent, func(), error) {
+	list_options, err := newListOptions([]ListOption{OptionListLabelSelector(selector)})
+	if err != nil {
+		return nil, nil, err
+	}
+	mapping, err := m.ResolveKind(kind)
+	if err != nil {
+		return nil, nil, err
+	}
+	dr, err := m.mappingResource(mapping, ns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	w := &watcher{
+		ctx:          ctx,
+		dr:           dr,
+		list_options: list_options,
+		known:        map[string]string{},
+		events:       make(chan WatchEvent),
+	}
+	list, err := dr.List(ctx, list_options)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	go func() {
+		defer close(w.events)
+		w.sync(list)
+		w.run()
+	}()
+	return w.events, cancel, nil
+}
+
+// run watches until the context is cancelled
+func (w *watcher) run() {
+	for w.ctx.Err() == nil {
+		opts := w.list_options
+		opts.ResourceVersion = w.resource_version
+		opts.AllowWatchBookmarks = true
+		wi, err := w.dr.Watch(w.ctx, opts)
+		if err != nil {
+			w.recover(err)
+			continue
+		}
+		err = w.consume(wi)
+		wi.Stop()
+		if err != nil {
+			w.recover(err)
+		}
+	}
+}
+
+// recover handles a watch error by listing again when the resourceVersion is too old
+// otherwise it waits before the watch is re-established
+// err: the watch error
+func (w *watcher) recover(err error) {
+	if w.ctx.Err() != nil {
+		return
+	}
+	if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+		log.Printf("Info: Watch expired, listing again\n")
+		list, err := w.dr.List(w.ctx, w.list_options)
+		if err == nil {
+			w.sync(list)
+			return
+		}
+		log.Printf("Info: Unable to list Error(%s)\n", err.Error())
+	} else {
+		log.Printf("Info: Watch failed retrying Error(%s)\n", err.Error())
+	}
+	select {
+	case <-w.ctx.Done():
+	case <-time.After(watchRetryDelay):
+	}
+}
+
+// consume sends the events from a watch until it closes
+// wi: the watch
+// return: error from the watch
+func (w *watcher) consume(wi watch.Interface) error {
+	for {
+		select {
+		case <-w.ctx.Done():
+			return nil
+		case ev, ok := <-wi.ResultChan():
+			if !ok {
+				// Disconnected so resume from the last resourceVersion
+				return nil
+			}
+			switch ev.Type {
+			case watch.Error:
+				return apierrors.FromObject(ev.Object)
+			case watch.Bookmark:
+				if obj, ok := ev.Object.(*unstructured.Unstructured); ok {
+					w.resource_version = obj.GetResourceVersion()
+				}
+			case watch.Added, watch.Modified, watch.Deleted:
+				obj, ok := ev.Object.(*unstructured.Unstructured)
+				if !ok {
+					continue
+				}
+				w.resource_version = obj.GetResourceVersion()
+				key := obj.GetNamespace() + "/" + obj.GetName()
+				if ev.Type == watch.Deleted {
+					delete(w.known, key)
+				} else {
+					w.known[key] = obj.GetResourceVersion()
+				}
+				w.send(WatchEvent{Type: WatchEventType(ev.Type), Object: obj})
+			}
+		}
+	}
+}
+
+// sync sends the differences between a list and the known objects
+// list: the listed objects
+func (w *watcher) sync(list *unstructured.UnstructuredList) {
+	current := map[string]bool{}
+	for i := range list.Items {
+		obj := &list.Items[i]
+		key := obj.GetNamespace() + "/" + obj.GetName()
+		current[key] = true
+		rv, found := w.known[key]
+		w.known[key] = obj.GetResourceVersion()
+		if !found {
+			w.send(WatchEvent{Type: WatchAdded, Object: obj})
+		} else if rv != obj.GetResourceVersion() {
+			w.send(WatchEvent{Type: WatchModified, Object: obj})
+		}
+	}
+
+	//*************************************
+	//Anything not listed has been deleted
+	//*************************************
+	for key := range w.known {
+		if current[key] {
+			continue
+		}
+		delete(w.known, key)
+		obj := &unstructured.Unstructured{}
+		ns, name, _ := strings.Cut(key, "/")
+		obj.SetNamespace(ns)
+		obj.SetName(name)
+		w.send(WatchEvent{Type: WatchDeleted, Object: obj})
+	}
+	w.resource_version = list.GetResourceVersion()
+}
+
+// send sends an event unless the watch is stopped
+func (w *watcher) send(ev WatchEvent) {
+	select {
+	case w.events <- ev:
+	case <-w.ctx.Done():
+	}
+}